@@ -3,6 +3,8 @@ module github.com/vinijabes/gocompositor
 go 1.13
 
 require (
+	github.com/pion/rtp v1.6.0
 	github.com/vinijabes/gostreamer v0.1.7-0.20200927010745-ab232afcffc3
 	golang.org/x/text v0.3.2 // indirect
+	gopkg.in/yaml.v2 v2.3.0
 )
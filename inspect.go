@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/vinijabes/gocompositor/pkg/config"
+)
+
+func inspectCommand(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	path := flags.String("config", "", "configuration `file`")
+	all := flags.Bool("all", false, "print every element property, not only the ones changed from the default")
+	dot := flags.Bool("dot", false, "print the pipeline as a Graphviz graph")
+	flags.Parse(args)
+
+	if *path == "" {
+		flags.Usage()
+		return ErrNoConfig
+	}
+
+	c, err := config.Load(*path)
+	if err != nil {
+		return err
+	}
+
+	instance, err := config.Build(c)
+	if err != nil {
+		return err
+	}
+	defer instance.Compositor.Stop()
+
+	if *dot {
+		fmt.Print(instance.Compositor.Dot())
+		return nil
+	}
+
+	fmt.Print(instance.Compositor.Describe(*all))
+	return nil
+}
@@ -1,167 +1,60 @@
 package main
 
 import (
-	"log"
-	"time"
-
-	"github.com/vinijabes/gocompositor/pkg/compositor"
-	"github.com/vinijabes/gocompositor/pkg/compositor/element"
-	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+	"fmt"
+	"os"
 )
 
-func handleMessages(c <-chan gstreamer.Message) {
-	log.Println("Start handling messages")
-	for msg := range c {
-		log.Println(msg.GetName())
-	}
-	log.Println("Stop handling messages")
+//command is a gocompositor subcommand, run gets the arguments after its name
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
 }
 
-func main() {
-	cmp, err := compositor.NewCompositor()
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	video, err := element.NewVideoRTSP(640, 360, "rstp://ip", 0)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	video2, err := element.NewVideoRTSP(640, 360, "rstp://ip", 500)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	video3, err := element.NewVideoRTSP(640, 360, "rstp://ip", 1000)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	video4, err := element.NewVideoRTSP(640, 360, "rstp://ip", 2000)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	err = cmp.AddVideo(video)
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	convert, err := gstreamer.NewElement("videoconvert", "convert")
-	if err != nil {
-		log.Fatalln(err)
-	}
-
-	convert.Set("n-threads", 4)
-
-	// enc, err := gstreamer.NewElement("x264enc", "enc")
-	// if err != nil {
-	// 	log.Fatalln(err)
-	// }
-
-	// mux, err := gstreamer.NewElement("flvmux", "mux")
-	// if err != nil {
-	// 	log.Fatalln(err)
-	// }
+var commands = []command{
+	{name: "run", description: "start compositors from configuration files", run: runCommand},
+	{name: "validate", description: "check configuration files and their layouts", run: validateCommand},
+	{name: "inspect", description: "print the pipeline built from a configuration file", run: inspectCommand},
+	{name: "plugins", description: "list the GStreamer plugins used and whether they are installed", run: pluginsCommand},
+}
 
-	sink, err := gstreamer.NewElement("autovideosink", "sink")
-	if err != nil {
-		log.Fatalln(err)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: gocompositor <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run gocompositor <command> -h for the flags of a command")
+}
 
-	cmp.Add(convert)
-	// cmp.Add(enc)
-	// cmp.Add(mux)
-	cmp.Add(sink)
-
-	cmp.LinkVideoSink(convert)
-	convert.Link(sink)
-	// enc.Link(mux)
-	// mux.Link(sink)
-
-	//sink.Set("location", "rtmp://teste.com")
-
-	layout := compositor.NewLayout(1280, 720)
-	videoRule1 := compositor.NewLayoutRule()
-	slot1 := compositor.NewLayoutSlotWithSymetricBorders(0, 0, 640, 360, 320, 180)
-
-	videoRule1.AddSlot(slot1)
-
-	videoRule2 := compositor.NewLayoutRule()
-	slot2 := compositor.NewLayoutSlotWithSymetricBorders(0, 0, 640, 360, 0, 180)
-	slot3 := compositor.NewLayoutSlotWithSymetricBorders(640, 0, 640, 360, 0, 180)
-
-	videoRule2.AddSlot(slot2)
-	videoRule2.AddSlot(slot3)
-
-	videoRule3 := compositor.NewLayoutRule()
-	slot4 := compositor.NewLayoutSlot(0, 0, 640, 360)
-	slot5 := compositor.NewLayoutSlot(640, 0, 640, 360)
-	slot6 := compositor.NewLayoutSlot(320, 360, 640, 360)
-
-	videoRule3.AddSlot(slot4)
-	videoRule3.AddSlot(slot5)
-	videoRule3.AddSlot(slot6)
-
-	videoRule4 := compositor.NewLayoutRule()
-	slot7 := compositor.NewLayoutSlot(0, 0, 640, 360)
-	slot8 := compositor.NewLayoutSlot(640, 0, 640, 360)
-	slot9 := compositor.NewLayoutSlot(0, 360, 640, 360)
-	slot10 := compositor.NewLayoutSlot(640, 360, 640, 360)
-
-	videoRule4.AddSlot(slot7)
-	videoRule4.AddSlot(slot8)
-	videoRule4.AddSlot(slot9)
-	videoRule4.AddSlot(slot10)
-
-	layout.AddRule(videoRule1, 1)
-	layout.AddRule(videoRule2, 2)
-	layout.AddRule(videoRule3, 3)
-	layout.AddRule(videoRule4, 4)
-	cmp.SetLayout(layout)
-
-	// _, err = compositor.NewRTCVideo(compositor.CodecVP8, 640, 360)
-	// if err != nil {
-	// 	panic(err)
-	// }
-
-	// box2.SetPos(int64(640), int64(0))
-	// box3.SetPos(int64(0), int64(360))
-	// box4.SetPos(int64(640), int64(360))
-
-	cmp.Start()
-	defer cmp.Stop()
-
-	time.Sleep(20 * time.Second)
-
-	cmp.Pause()
-	err = cmp.AddVideo(video2)
-	if err != nil {
-		log.Fatalln(err)
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
-	cmp.Start()
-
-	time.Sleep(20 * time.Second)
 
-	cmp.Pause()
-	err = cmp.AddVideo(video3)
-	if err != nil {
-		log.Fatalln(err)
+	name := os.Args[1]
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		usage()
+		return
 	}
 
-	cmp.Start()
-	time.Sleep(20 * time.Second)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
 
-	cmp.Pause()
-	err = cmp.AddVideo(video4)
-	if err != nil {
-		log.Fatalln(err)
+		if err := c.run(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	cmp.Start()
-
-	for {
-
-	}
+	fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", name)
+	usage()
+	os.Exit(2)
 }
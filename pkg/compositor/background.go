@@ -0,0 +1,271 @@
+package compositor
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//backgroundZOrder keeps the background below every source
+const backgroundZOrder uint32 = 0
+
+//backgroundLayer is a chain feeding the background mixer pad
+type backgroundLayer interface {
+	add(pipeline gstreamer.Pipeline, sink gstreamer.Pad) error
+	remove(pipeline gstreamer.Pipeline, sink gstreamer.Pad)
+	setState(state gstreamer.GstState)
+}
+
+//Background is a background layer built ahead of SetBackground, so a failing
+//one doesn't take the current background away
+type Background struct {
+	layer backgroundLayer
+}
+
+//colorLayer is a solid colour the size of the canvas
+type colorLayer struct {
+	source gstreamer.Element
+	filter gstreamer.Element
+}
+
+//videoLayer loops a file source over the canvas, the file decodes in its own
+//pipeline like any other file source
+type videoLayer struct {
+	video element.VideoFile
+}
+
+//backgroundIDGenerator is atomic, backgrounds can be prepared concurrently
+var backgroundIDGenerator int64
+
+func newColorLayer(color uint32, width int, height int) (*colorLayer, error) {
+	id := atomic.AddInt64(&backgroundIDGenerator, 1) - 1
+
+	source, err := gstreamer.NewElement("videotestsrc", fmt.Sprintf("background_source_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	source.Set("pattern", 17) //solid-color
+	source.Set("foreground-color", color)
+	source.Set("is-live", true)
+
+	filter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("background_filter_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,width=%d,height=%d", width, height))
+	if err != nil {
+		return nil, err
+	}
+	filter.Set("caps", caps)
+
+	return &colorLayer{
+		source: source,
+		filter: filter,
+	}, nil
+}
+
+func (l *colorLayer) add(pipeline gstreamer.Pipeline, sink gstreamer.Pad) error {
+	if !pipeline.Add(l.source) || !pipeline.Add(l.filter) || !l.source.Link(l.filter) {
+		return fmt.Errorf("Failed to add background colour")
+	}
+
+	srcpad, err := l.filter.GetStaticPad("src")
+	if err != nil {
+		return err
+	}
+
+	if result := srcpad.Link(sink); result != gstreamer.GstPadLinkOk {
+		return fmt.Errorf("Failed to link background with mixer: %d", result)
+	}
+
+	return nil
+}
+
+func (l *colorLayer) remove(pipeline gstreamer.Pipeline, sink gstreamer.Pad) {
+	if srcpad, err := l.filter.GetStaticPad("src"); err == nil {
+		srcpad.Unlink(sink)
+	}
+
+	l.filter.SetState(gstreamer.GstStateNull)
+	l.source.SetState(gstreamer.GstStateNull)
+	pipeline.Remove(l.filter)
+	pipeline.Remove(l.source)
+}
+
+func (l *colorLayer) setState(state gstreamer.GstState) {
+	l.filter.SetState(state)
+	l.source.SetState(state)
+}
+
+func newVideoLayer(uri string, width int, height int) (*videoLayer, error) {
+	video, err := element.NewVideoFile(width, height, uri)
+	if err != nil {
+		return nil, err
+	}
+	video.SetLoop(true)
+
+	return &videoLayer{video: video}, nil
+}
+
+func (l *videoLayer) add(pipeline gstreamer.Pipeline, sink gstreamer.Pad) error {
+	if err := l.video.SetPipeline(pipeline); err != nil {
+		return err
+	}
+
+	result, err := l.video.LinkSinkPad(sink)
+	if err != nil {
+		return err
+	}
+
+	if result != gstreamer.GstPadLinkOk {
+		return fmt.Errorf("Failed to link background with mixer: %d", result)
+	}
+
+	return nil
+}
+
+func (l *videoLayer) remove(pipeline gstreamer.Pipeline, sink gstreamer.Pad) {
+	l.video.SetState(gstreamer.GstStateNull)
+
+	if err := l.video.ClearPipeline(); err != nil {
+		logging.Error(err)
+	}
+}
+
+func (l *videoLayer) setState(state gstreamer.GstState) {
+	l.video.SetState(state)
+}
+
+//NewBackgroundColor prepares a solid ARGB colour the size of the canvas for SetBackground
+func (c *Compositor) NewBackgroundColor(color uint32) (*Background, error) {
+	layer, err := newColorLayer(color, c.mixer.width, c.mixer.height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Background{layer: layer}, nil
+}
+
+//NewBackgroundImage prepares a picture stretched over the canvas for SetBackground
+func (c *Compositor) NewBackgroundImage(location string) (*Background, error) {
+	layer, err := newStillImage(location, c.mixer.width, c.mixer.height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Background{layer: layer}, nil
+}
+
+//NewBackgroundVideo prepares a video file or URI looped over the canvas for SetBackground
+func (c *Compositor) NewBackgroundVideo(uri string) (*Background, error) {
+	layer, err := newVideoLayer(uri, c.mixer.width, c.mixer.height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Background{layer: layer}, nil
+}
+
+//Stats returns the stats of a video background, colours and pictures have none
+func (b *Background) Stats() element.SourceStats {
+	if layer, ok := b.layer.(*videoLayer); ok {
+		return layer.video.Stats()
+	}
+
+	return element.SourceStats{}
+}
+
+//SetBackground replaces the background layer with a prepared one, a
+//background can only be set once
+func (c *Compositor) SetBackground(b *Background) error {
+	return c.setBackground(b.layer)
+}
+
+//SetBackgroundColor fills the canvas behind the sources with an ARGB colour
+func (c *Compositor) SetBackgroundColor(color uint32) error {
+	b, err := c.NewBackgroundColor(color)
+	if err != nil {
+		return err
+	}
+
+	return c.SetBackground(b)
+}
+
+//SetBackgroundImage stretches a picture over the canvas behind the sources
+func (c *Compositor) SetBackgroundImage(location string) error {
+	b, err := c.NewBackgroundImage(location)
+	if err != nil {
+		return err
+	}
+
+	return c.SetBackground(b)
+}
+
+//SetBackgroundVideo loops a video file or URI over the canvas behind the sources
+func (c *Compositor) SetBackgroundVideo(uri string) error {
+	b, err := c.NewBackgroundVideo(uri)
+	if err != nil {
+		return err
+	}
+
+	return c.SetBackground(b)
+}
+
+//ClearBackground removes the background layer, leaving the mixer black background
+func (c *Compositor) ClearBackground() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeBackground()
+}
+
+//setBackground links the new layer on its own pad before the current one is
+//removed, a failing layer leaves the current background on screen
+func (c *Compositor) setBackground(layer backgroundLayer) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sink, err := c.mixer.requestPad()
+	if err != nil {
+		return err
+	}
+
+	sink.Set("xpos", 0)
+	sink.Set("ypos", 0)
+	sink.Set("zorder", backgroundZOrder)
+	sink.Set("alpha", float32(1))
+
+	if err := layer.add(c.pipeline, sink); err != nil {
+		layer.remove(c.pipeline, sink)
+		c.mixer.releasePad(sink)
+		return err
+	}
+
+	if c.state != gstreamer.GstStateNull {
+		//setting the current state again brings the new elements to it
+		c.pipeline.SetState(c.state)
+		layer.setState(c.state)
+	}
+
+	c.removeBackground()
+	c.background = layer
+	c.backgroundSink = sink
+
+	return nil
+}
+
+//removeBackground takes the layer out and gives its pad back, the mutex must be held
+func (c *Compositor) removeBackground() {
+	if c.background == nil {
+		return
+	}
+
+	c.background.remove(c.pipeline, c.backgroundSink)
+	c.mixer.releasePad(c.backgroundSink)
+	c.background = nil
+	c.backgroundSink = nil
+}
@@ -3,6 +3,9 @@ package compositor
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vinijabes/gocompositor/pkg/compositor/element"
@@ -12,13 +15,36 @@ import (
 
 //Compositor ...
 type Compositor struct {
+	//busErrors is updated atomically, it comes first to be 64 bit aligned
+	busErrors uint64
+
 	pipeline   gstreamer.Pipeline
 	mixer      *Mixer
 	audioMixer *AudioMixer
 	layout     *Layout
+	scenes     map[string]*Scene
+	scene      *Scene
+	transition chan struct{}
+	overlays   map[string]*ImageOverlay
+	background backgroundLayer
 	videos     element.Videos
-	audios     []gstreamer.Element
+	audios     map[gstreamer.Element]gstreamer.Pad
 	eos        bool
+	eosDone    chan struct{}
+	eosOnce    sync.Once
+	state      gstreamer.GstState
+
+	backgroundSink gstreamer.Pad
+	handlers       []element.EventHandler
+	statsDone      chan struct{}
+	output         *element.RateCounter
+	outputs        map[string]*element.RateCounter
+	sinks          map[element.Video]gstreamer.Pad
+	outputTee      *outputTee
+	outputChains   map[string]*Output
+
+	mutex        sync.Mutex
+	handlerMutex sync.Mutex
 }
 
 //Mixer ...
@@ -26,6 +52,12 @@ type Mixer struct {
 	gstMixer        gstreamer.Element
 	gstOutputFilter gstreamer.Element
 	gstPadTemplate  gstreamer.PadTemplate
+	clock           *element.Clock
+
+	sources uint32
+
+	width  int
+	height int
 }
 
 //Mixer ...
@@ -36,9 +68,33 @@ type AudioMixer struct {
 
 var pipelineIDGenerator = 0
 
-var ErrCreateCompositor = errors.New("Failed to create compositor")
+var (
+	ErrCreateCompositor = errors.New("Failed to create compositor")
+	ErrOutputExists     = errors.New("Output already exists")
+	ErrOutputNotFound   = errors.New("Output not found")
+	ErrVideoNotFound    = errors.New("Video not found")
+	ErrAudioNotFound    = errors.New("Audio not found")
+)
+
+//audioVideo is a source with an audio branch added with AddAudio
+type audioVideo interface {
+	Audio() gstreamer.Element
+}
+
+//removeBlockTimeout bounds the wait for a source streaming thread to be held
+//before its elements are taken out, a stalled source has nothing in flight
+const removeBlockTimeout = 500 * time.Millisecond
 
-func printBusMessages(bus gstreamer.Bus) {
+//Canvas size of NewCompositor
+const (
+	DefaultCanvasWidth  = 1280
+	DefaultCanvasHeight = 720
+)
+
+//sourceZOrderBase keeps sources above the background
+const sourceZOrderBase uint32 = 1
+
+func (c *Compositor) printBusMessages(bus gstreamer.Bus) {
 	for {
 		for bus.HavePending() {
 			message, err := bus.Pop()
@@ -47,7 +103,10 @@ func printBusMessages(bus gstreamer.Bus) {
 			if err != nil {
 				fmt.Println(err)
 			} else if message.GetType() == gstreamer.MessageError {
+				atomic.AddUint64(&c.busErrors, 1)
 				fmt.Println(message.GetStructure())
+			} else if message.GetType() == gstreamer.MessageEOS {
+				c.eosOnce.Do(func() { close(c.eosDone) })
 			}
 		}
 		time.Sleep(1 * time.Second)
@@ -56,12 +115,17 @@ func printBusMessages(bus gstreamer.Bus) {
 
 //NewCompositor ...
 func NewCompositor() (*Compositor, error) {
+	return NewCompositorWithCanvas(DefaultCanvasWidth, DefaultCanvasHeight)
+}
+
+//NewCompositorWithCanvas creates a compositor with a width x height canvas
+func NewCompositorWithCanvas(width int, height int) (*Compositor, error) {
 	pipeline, err := gstreamer.NewPipeline(fmt.Sprintf("compositor_%d", pipelineIDGenerator))
 	if err != nil {
 		return nil, err
 	}
 
-	mixer, err := newMixer(pipelineIDGenerator)
+	mixer, err := newMixer(width, height, pipelineIDGenerator)
 	if err != nil {
 		return nil, err
 	}
@@ -71,26 +135,52 @@ func NewCompositor() (*Compositor, error) {
 		return nil, err
 	}
 
+	tee, err := newOutputTee(pipelineIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
 	compositor := &Compositor{
-		pipeline:   pipeline,
-		mixer:      mixer,
-		audioMixer: audioMixer,
+		pipeline:     pipeline,
+		mixer:        mixer,
+		audioMixer:   audioMixer,
+		scenes:       make(map[string]*Scene),
+		overlays:     make(map[string]*ImageOverlay),
+		outputs:      make(map[string]*element.RateCounter),
+		sinks:        make(map[element.Video]gstreamer.Pad),
+		audios:       make(map[gstreamer.Element]gstreamer.Pad),
+		outputTee:    tee,
+		outputChains: make(map[string]*Output),
+		eosDone:      make(chan struct{}),
+		state:        gstreamer.GstStateNull,
 	}
 
-	if !pipeline.Add(mixer.gstMixer) || !pipeline.Add(mixer.gstOutputFilter) || !pipeline.Add(audioMixer.gstMixer) {
+	if !pipeline.Add(mixer.gstMixer) ||
+		!pipeline.Add(mixer.gstOutputFilter) ||
+		!pipeline.Add(mixer.clock.Raw()) ||
+		!pipeline.Add(tee.tee) ||
+		!pipeline.Add(audioMixer.gstMixer) {
 		return nil, ErrCreateCompositor
 	}
 
 	bus, _ := pipeline.GetBus()
-	go printBusMessages(bus)
+	go compositor.printBusMessages(bus)
 
 	mixer.gstMixer.Link(mixer.gstOutputFilter)
+	mixer.gstOutputFilter.Link(mixer.clock.Raw())
+	mixer.clock.Raw().Link(tee.tee)
+
+	outputpad, err := mixer.clock.Raw().GetStaticPad("src")
+	if err != nil {
+		return nil, err
+	}
+	compositor.output = element.NewRateCounter(outputpad)
 
 	pipelineIDGenerator++
 	return compositor, nil
 }
 
-func newMixer(id int) (*Mixer, error) {
+func newMixer(width int, height int, id int) (*Mixer, error) {
 	videomixer, err := gstreamer.NewElement("compositor", fmt.Sprintf("videomixer_%d", id))
 	if err != nil {
 		return nil, err
@@ -107,16 +197,24 @@ func newMixer(id int) (*Mixer, error) {
 		return nil, err
 	}
 
-	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,width=%d,height=%d", 1280, 720))
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,width=%d,height=%d", width, height))
 	if err != nil {
 		return nil, err
 	}
 	capsfilter.Set("caps", caps)
 
+	clock, err := element.NewClock(fmt.Sprintf("canvas_clock_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
 	mixer := &Mixer{
 		gstMixer:        videomixer,
 		gstPadTemplate:  padTemplate,
 		gstOutputFilter: capsfilter,
+		clock:           clock,
+		width:           width,
+		height:          height,
 	}
 
 	return mixer, nil
@@ -142,41 +240,373 @@ func newAudioMixer(id int) (*AudioMixer, error) {
 
 //AddVideo add new video
 func (c *Compositor) AddVideo(v element.Video) error {
-	pipeline := c.pipeline
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.attachVideo(v); err != nil {
+		return err
+	}
 
-	err := v.SetPipeline(pipeline)
+	c.videos = append(c.videos, v)
+	c.applyLayout()
+
+	return nil
+}
+
+//RemoveVideo stops a source and takes it out of the compositor and its
+//scenes. The pipeline owned the source elements, it can't be added again
+func (c *Compositor) RemoveVideo(v element.Video) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	index := c.videoIndex(v)
+	if index < 0 {
+		return ErrVideoNotFound
+	}
+
+	if err := c.detachVideo(v); err != nil {
+		return err
+	}
+
+	c.videos = append(c.videos[:index], c.videos[index+1:]...)
+	for _, s := range c.scenes {
+		s.removeVideo(v)
+	}
+	c.applyLayout()
+
+	return nil
+}
+
+//ReplaceVideo swaps a source for another one in its place, the new source
+//takes the old one position in the layout and in the scenes showing it
+func (c *Compositor) ReplaceVideo(old element.Video, v element.Video) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	index := c.videoIndex(old)
+	if index < 0 {
+		return ErrVideoNotFound
+	}
+
+	if err := c.detachVideo(old); err != nil {
+		return err
+	}
+
+	if err := c.attachVideo(v); err != nil {
+		c.videos = append(c.videos[:index], c.videos[index+1:]...)
+		for _, s := range c.scenes {
+			s.removeVideo(old)
+		}
+		c.applyLayout()
+		return err
+	}
+
+	c.videos[index] = v
+	for _, s := range c.scenes {
+		s.replaceVideo(old, v)
+	}
+	c.applyLayout()
+
+	return nil
+}
+
+func (c *Compositor) videoIndex(v element.Video) int {
+	for i, video := range c.videos {
+		if video == v {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//attachVideo adds the source elements to the pipeline and links them into a mixer pad
+func (c *Compositor) attachVideo(v element.Video) error {
+	err := v.SetPipeline(c.pipeline)
 	if err != nil {
 		return err
 	}
 
-	err = c.mixer.link(v)
+	sink, err := c.mixer.link(v)
 	if err != nil {
 		return err
 	}
+	c.sinks[v] = sink
 
-	c.videos = append(c.videos, v)
+	v.OnEvent(c.emit)
 
-	if c.layout != nil {
-		c.layout.ApplyLayout(c.videos)
+	if c.state != gstreamer.GstStateNull {
+		//setting the current state again brings the new elements to it
+		c.pipeline.SetState(c.state)
+
+		if s, ok := v.(element.StatefulVideo); ok {
+			s.SetState(c.state)
+		}
+	}
+
+	return nil
+}
+
+//detachVideo stops the source and takes its elements and its audio out of
+//the pipeline, the data going into its mixer pad is held meanwhile
+func (c *Compositor) detachVideo(v element.Video) error {
+	if a, ok := v.(audioVideo); ok && a.Audio() != nil {
+		if _, added := c.audios[a.Audio()]; added {
+			if err := c.removeAudio(a.Audio()); err != nil {
+				return err
+			}
+		}
+	}
+
+	var probe *element.PadProbe
+	if sink, ok := c.sinks[v]; ok {
+		probe = c.blockPeer(sink)
+	}
+
+	if s, ok := v.(element.StatefulVideo); ok {
+		s.SetState(gstreamer.GstStateNull)
+	}
+
+	err := v.ClearPipeline()
+	if probe != nil {
+		probe.Remove()
+	}
+	if err != nil {
+		return err
+	}
+
+	if sink, ok := c.sinks[v]; ok {
+		c.mixer.releasePad(sink)
+		delete(c.sinks, v)
+	}
+
+	return nil
+}
+
+//Videos returns the sources in the order they were added
+func (c *Compositor) Videos() element.Videos {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	videos := make(element.Videos, len(c.videos))
+	copy(videos, c.videos)
+
+	return videos
+}
+
+//OnEvent registers a handler for the events of every source
+func (c *Compositor) OnEvent(handler element.EventHandler) {
+	c.handlerMutex.Lock()
+	defer c.handlerMutex.Unlock()
+
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *Compositor) emit(event element.Event) {
+	c.handlerMutex.Lock()
+	handlers := make([]element.EventHandler, len(c.handlers))
+	copy(handlers, c.handlers)
+	c.handlerMutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+//Stats returns the stats of every source
+func (c *Compositor) Stats() []element.SourceStats {
+	videos := c.Videos()
+
+	stats := make([]element.SourceStats, len(videos))
+	for i, v := range videos {
+		stats[i] = v.Stats()
+	}
+
+	return stats
+}
+
+//SetStatsInterval emits an EventStats for every source each interval, zero stops it
+func (c *Compositor) SetStatsInterval(interval time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.statsDone != nil {
+		close(c.statsDone)
+		c.statsDone = nil
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	c.statsDone = done
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			for _, stats := range c.Stats() {
+				stats := stats
+				c.emit(element.Event{
+					Type:    element.EventStats,
+					Source:  stats.Source,
+					Time:    time.Now(),
+					Message: stats.String(),
+					Stats:   &stats,
+				})
+			}
+		}
+	}()
+}
+
+//State returns the state the compositor was last set to
+func (c *Compositor) State() gstreamer.GstState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.state
+}
+
+//SourceCount returns how many sources were added
+func (c *Compositor) SourceCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.videos)
+}
+
+//OutputStats returns the frame rate of the composed canvas
+func (c *Compositor) OutputStats() element.RateStats {
+	return c.output.Stats()
+}
+
+//WatchOutput counts the buffers reaching sink, usually the last element of
+//an output chain linked with LinkVideoSink, to report the output bitrate
+func (c *Compositor) WatchOutput(name string, sink gstreamer.Element) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, exists := c.outputs[name]; exists {
+		return ErrOutputExists
+	}
+
+	pad, err := sink.GetStaticPad("sink")
+	if err != nil {
+		return err
 	}
 
+	c.outputs[name] = element.NewRateCounter(pad)
+
+	return nil
+}
+
+//UnwatchOutput stops counting an output
+func (c *Compositor) UnwatchOutput(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counter, exists := c.outputs[name]
+	if !exists {
+		return ErrOutputNotFound
+	}
+
+	counter.Remove()
+	delete(c.outputs, name)
+
 	return nil
 }
 
+//Outputs returns the stats of the watched outputs by name
+func (c *Compositor) Outputs() map[string]element.RateStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	outputs := make(map[string]element.RateStats, len(c.outputs))
+	for name, counter := range c.outputs {
+		outputs[name] = counter.Stats()
+	}
+
+	return outputs
+}
+
+//BusErrors returns how many errors the pipeline posted
+func (c *Compositor) BusErrors() uint64 {
+	return atomic.LoadUint64(&c.busErrors)
+}
+
+//Latency returns the pipeline latency, -1 when it isn't playing
+func (c *Compositor) Latency() time.Duration {
+	return element.QueryLatency(c.pipeline)
+}
+
 //AddAudio add new audio
 func (c *Compositor) AddAudio(a gstreamer.Element) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	c.pipeline.Add(a)
 
-	err := c.audioMixer.link(a)
+	sink, err := c.audioMixer.link(a)
 	if err != nil {
 		return err
 	}
 
-	c.audios = append(c.audios, a)
+	c.audios[a] = sink
 
 	return nil
 }
 
+//RemoveAudio stops an audio element added with AddAudio and takes it out of
+//the pipeline, RemoveVideo already does it for the audio of a source
+func (c *Compositor) RemoveAudio(a gstreamer.Element) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.removeAudio(a)
+}
+
+func (c *Compositor) removeAudio(a gstreamer.Element) error {
+	sink, ok := c.audios[a]
+	if !ok {
+		return ErrAudioNotFound
+	}
+
+	probe := c.blockPeer(sink)
+
+	if srcpad, err := a.GetStaticPad("src"); err == nil {
+		srcpad.Unlink(sink)
+	}
+	a.SetState(gstreamer.GstStateNull)
+	c.pipeline.Remove(a)
+
+	if probe != nil {
+		probe.Remove()
+	}
+
+	element.ReleaseRequestPad(c.audioMixer.gstMixer, sink)
+	delete(c.audios, a)
+
+	return nil
+}
+
+//blockPeer holds the data going into a mixer pad before its upstream
+//elements are taken out, nothing flows unless the compositor is playing
+func (c *Compositor) blockPeer(sink gstreamer.Pad) *element.PadProbe {
+	if c.state != gstreamer.GstStatePlaying {
+		return nil
+	}
+
+	return element.BlockPeer(sink, removeBlockTimeout)
+}
+
 //Add ...
 func (c *Compositor) Add(e gstreamer.Element) {
 	c.pipeline.Add(e)
@@ -185,18 +615,36 @@ func (c *Compositor) Add(e gstreamer.Element) {
 //Start ...
 func (c *Compositor) Start() {
 	if !c.eos {
-		c.pipeline.SetState(gstreamer.GstStatePlaying)
+		c.setState(gstreamer.GstStatePlaying)
 	}
 }
 
 //Stop ...
 func (c *Compositor) Stop() {
-	c.pipeline.SetState(gstreamer.GstStateNull)
+	c.setState(gstreamer.GstStateNull)
 }
 
 //Pause ...
 func (c *Compositor) Pause() {
-	c.pipeline.SetState(gstreamer.GstStatePaused)
+	c.setState(gstreamer.GstStatePaused)
+}
+
+func (c *Compositor) setState(state gstreamer.GstState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.pipeline.SetState(state)
+	c.state = state
+
+	for _, v := range c.videos {
+		if s, ok := v.(element.StatefulVideo); ok {
+			s.SetState(state)
+		}
+	}
+
+	if c.background != nil {
+		c.background.setState(state)
+	}
 }
 
 //SendEOS ...
@@ -208,16 +656,117 @@ func (c *Compositor) SendEOS() {
 	c.eos = true
 }
 
-//SetLayout ...
+//Shutdown stops the compositor gracefully: while playing, EOS is sent so
+//muxers can finalize their files and the pipeline is stopped once it reached
+//the sinks or after timeout. It returns false on timeout
+func (c *Compositor) Shutdown(timeout time.Duration) bool {
+	finished := true
+
+	if c.State() == gstreamer.GstStatePlaying {
+		c.SendEOS()
+
+		select {
+		case <-c.eosDone:
+		case <-time.After(timeout):
+			finished = false
+		}
+	}
+
+	c.SetStatsInterval(0)
+	c.Stop()
+
+	return finished
+}
+
+//Describe lists the pipeline elements with their links and the properties
+//changed from their default value, or all of them. The pipelines of sources
+//decoding on their own follow the compositor pipeline
+func (c *Compositor) Describe(all bool) string {
+	var description strings.Builder
+	for _, pipeline := range c.pipelines() {
+		description.WriteString(element.DescribePipeline(pipeline, all))
+	}
+
+	return description.String()
+}
+
+//Dot returns the pipelines as Graphviz graphs, one per pipeline
+func (c *Compositor) Dot() string {
+	var dot strings.Builder
+	for _, pipeline := range c.pipelines() {
+		dot.WriteString(element.PipelineDot(pipeline))
+	}
+
+	return dot.String()
+}
+
+//pipelines returns the compositor pipeline followed by the source pipelines
+func (c *Compositor) pipelines() []gstreamer.Pipeline {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pipelines := []gstreamer.Pipeline{c.pipeline}
+	for _, v := range c.videos {
+		if p, ok := v.(element.PipelineVideo); ok {
+			pipelines = append(pipelines, p.SourcePipeline())
+		}
+	}
+
+	if layer, ok := c.background.(*videoLayer); ok {
+		if p, ok := layer.video.(element.PipelineVideo); ok {
+			pipelines = append(pipelines, p.SourcePipeline())
+		}
+	}
+
+	return pipelines
+}
+
+//SetLayout sets a layout for every source, leaving the active scene if any
 func (c *Compositor) SetLayout(l *Layout) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	c.layout = l
 
+	if c.scene != nil {
+		for _, o := range c.scene.overlays {
+			o.SetVisible(false)
+		}
+		c.scene = nil
+	}
+
+	for _, v := range c.videos {
+		v.SetAlpha(1)
+	}
+
 	l.ApplyLayout(c.videos)
 }
 
+func (c *Compositor) applyLayout() error {
+	if c.scene != nil {
+		return c.scene.apply(c.videos, c.scene, 1)
+	}
+
+	if c.layout != nil {
+		return c.layout.ApplyLayout(c.videos)
+	}
+
+	return nil
+}
+
 //LinkVideoSink ...
 func (c *Compositor) LinkVideoSink(e gstreamer.Element) {
-	c.mixer.gstOutputFilter.Link(e)
+	c.outputTee.tee.Link(e)
+}
+
+//Canvas returns the canvas size
+func (c *Compositor) Canvas() (int, int) {
+	return c.mixer.width, c.mixer.height
+}
+
+//Clock returns the canvas clock overlay, drawn over the whole output
+func (c *Compositor) Clock() *element.Clock {
+	return c.mixer.clock
 }
 
 //LinkAudioSink ...
@@ -225,44 +774,56 @@ func (c *Compositor) LinkAudioSink(e gstreamer.Element) {
 	c.audioMixer.gstMixer.Link(e)
 }
 
-func (m *Mixer) link(v element.Video) error {
-	sink, err := m.gstMixer.RequestPad(m.gstPadTemplate, nil, nil)
+func (m *Mixer) requestPad() (gstreamer.Pad, error) {
+	return m.gstMixer.RequestPad(m.gstPadTemplate, nil, nil)
+}
+
+//releasePad gives an unlinked pad back to the mixer, a non live aggregator
+//would otherwise wait for data on it
+func (m *Mixer) releasePad(sink gstreamer.Pad) {
+	element.ReleaseRequestPad(m.gstMixer, sink)
+}
+
+func (m *Mixer) link(v element.Video) (gstreamer.Pad, error) {
+	sink, err := m.requestPad()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	sink.Set("alpha", 1.0)
+	sink.Set("alpha", float32(1))
+	sink.Set("zorder", sourceZOrderBase+m.sources)
+	m.sources++
 	result, err := v.LinkSinkPad(sink)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if result != gstreamer.GstPadLinkOk {
-		return errors.New("Failed to link sink with video element")
+		return nil, errors.New("Failed to link sink with video element")
 	}
 
-	return nil
+	return sink, nil
 }
 
-func (m *AudioMixer) link(a gstreamer.Element) error {
+func (m *AudioMixer) link(a gstreamer.Element) (gstreamer.Pad, error) {
 	sink, err := m.gstMixer.RequestPad(m.gstPadTemplate, nil, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	srcpad, err := a.GetStaticPad("src")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	result := srcpad.Link(sink)
 
 	if result != gstreamer.GstPadLinkOk {
-		return fmt.Errorf("Failed to link sink with auido element: %d", result)
+		return nil, fmt.Errorf("Failed to link sink with auido element: %d", result)
 	}
 
-	return nil
+	return sink, nil
 }
 
 //SetDebugLogger sets the debug logger function
@@ -0,0 +1,141 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+type videoDescription struct {
+	video
+	bin         gstreamer.Bin
+	convert     gstreamer.Element
+	videoscale  gstreamer.Element
+	videofilter gstreamer.Element
+	queue       gstreamer.Element
+}
+
+var ErrVideoDescription = errors.New("Invalid pipeline description")
+
+//NewVideoFromDescription creates a source from a gst-launch style chain such as
+//"v4l2src device=/dev/video0 ! video/x-raw,framerate=30/1", parsed by GStreamer
+//into a bin. Its one unlinked src pad goes through the standard tail, a chain
+//ending in a dynamic pad element like decodebin needs a "! videoconvert"
+func NewVideoFromDescription(desc string, width int, height int) (Video, error) {
+	if strings.TrimSpace(desc) == "" {
+		return nil, ErrVideoDescription
+	}
+
+	bin, err := gstreamer.NewBin(fmt.Sprintf("source_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parseBin(bin, desc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVideoDescription, err)
+	}
+
+	convert, err := gstreamer.NewElement("videoconvert", fmt.Sprintf("videoconvert_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videoscale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("videoscale_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	video := &videoDescription{}
+	err = video.initTail(videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	video.bin = bin
+	video.videosrc = bin
+	video.convert = convert
+	video.videoscale = videoscale
+	video.videofilter = videofilter
+	video.queue = queue
+
+	videoIDGenerator++
+	video.SetSize(width, height)
+
+	return video, nil
+}
+
+//chain returns the parsed bin followed by the elements shared with other sources
+func (v *videoDescription) chain() []gstreamer.Element {
+	return []gstreamer.Element{v.bin, v.convert, v.videoscale, v.videofilter, v.queue}
+}
+
+func (v *videoDescription) SetPipeline(pipeline gstreamer.Pipeline) error {
+	chain := v.chain()
+
+	if err := v.ClearPipeline(); err != nil {
+		return err
+	}
+
+	for _, element := range chain {
+		if !pipeline.Add(element) {
+			return ErrVideoSetPipeline
+		}
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		if !chain[i].Link(chain[i+1]) {
+			return ErrVideoLinkingSetPipeline
+		}
+	}
+
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
+	v.pipeline = pipeline
+
+	return nil
+}
+
+func (v *videoDescription) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	chain := v.chain()
+	for i := 0; i < len(chain)-1; i++ {
+		chain[i].Unlink(chain[i+1])
+	}
+
+	if !v.removeTail(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	for _, element := range chain {
+		if !removeElement(v.pipeline, element) {
+			return ErrVideoSetPipeline
+		}
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
+func (v *videoDescription) SetSize(width int, height int) {
+	caps, _ := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
+	v.videofilter.Set("caps", caps)
+	v.resizeTail(width, height)
+}
@@ -0,0 +1,225 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//VideoFile is a pre-recorded media source with its own playback controls
+type VideoFile interface {
+	StatefulVideo
+
+	Audio() gstreamer.Element
+
+	SetLoop(loop bool)
+	Seek(position time.Duration) error
+	Pause()
+	Resume()
+
+	Position() time.Duration
+	Duration() time.Duration
+}
+
+//StatefulVideo is a video running elements outside the compositor pipeline,
+//the compositor forwards its state changes to it
+type StatefulVideo interface {
+	Video
+	SetState(state gstreamer.GstState)
+}
+
+//PipelineVideo is a video decoding in its own pipeline, its frames reach the
+//compositor pipeline through an inter channel
+type PipelineVideo interface {
+	Video
+	SourcePipeline() gstreamer.Pipeline
+}
+
+type videoFile struct {
+	video
+	player      *player
+	videofilter gstreamer.Element
+	queue       gstreamer.Element
+	audiosrc    gstreamer.Element
+}
+
+var ErrVideoSeek = errors.New("Failed to seek video")
+
+//NewVideoFile creates a source playing a local file path or an URI
+func NewVideoFile(width int, height int, uri string) (VideoFile, error) {
+	uri, err := toURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	video := &videoFile{}
+
+	player, err := newPlayer(uri, videoIDGenerator, false)
+	if err != nil {
+		return nil, err
+	}
+
+	videosrc, err := newInterVideoSrc(fmt.Sprintf("source_%d", videoIDGenerator), player.videoChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	audiosrc, err := gstreamer.NewElement("interaudiosrc", fmt.Sprintf("audiosource_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+	audiosrc.Set("channel", player.audioChannel)
+
+	err = video.initTail(videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	video.videosrc = videosrc
+	video.player = player
+	video.videofilter = videofilter
+	video.queue = queue
+	video.audiosrc = audiosrc
+
+	//frames are counted before the inter channel, intervideosrc repeats the last one
+	video.framesPad, err = player.videosink.GetStaticPad("sink")
+	if err != nil {
+		return nil, err
+	}
+
+	player.onEOS = func() {
+		video.emit(video.Name(), EventEOS, uri)
+	}
+	player.onError = func(message string) {
+		video.stats.decodeError()
+		video.emit(video.Name(), EventError, message)
+	}
+
+	videoIDGenerator++
+	video.SetSize(width, height)
+
+	return video, nil
+}
+
+//toURI turns local paths into file URIs
+func toURI(location string) (string, error) {
+	if strings.Contains(location, "://") {
+		return location, nil
+	}
+
+	path, err := filepath.Abs(location)
+	if err != nil {
+		return "", err
+	}
+
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+func (v *videoFile) SetPipeline(pipeline gstreamer.Pipeline) error {
+	if err := v.ClearPipeline(); err != nil {
+		return err
+	}
+
+	if !pipeline.Add(v.videosrc) ||
+		!pipeline.Add(v.videofilter) ||
+		!pipeline.Add(v.queue) ||
+		!v.videosrc.Link(v.videofilter) ||
+		!v.videofilter.Link(v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
+	v.pipeline = pipeline
+
+	return nil
+}
+
+func (v *videoFile) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.videosrc.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
+func (v *videoFile) SetSize(width int, height int) {
+	caps, _ := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
+	v.videofilter.Set("caps", caps)
+	v.player.setSize(width, height)
+	v.resizeTail(width, height)
+}
+
+//SetState plays the file while the compositor plays, unless it was paused
+func (v *videoFile) SetState(state gstreamer.GstState) {
+	v.player.setState(state)
+}
+
+//SourcePipeline returns the pipeline decoding the file
+func (v *videoFile) SourcePipeline() gstreamer.Pipeline {
+	return v.player.pipeline
+}
+
+//Audio returns the element carrying the file audio, add it with Compositor.AddAudio
+func (v *videoFile) Audio() gstreamer.Element {
+	return v.audiosrc
+}
+
+//SetLoop restarts the file when it ends instead of holding the last frame
+func (v *videoFile) SetLoop(loop bool) {
+	v.player.setLoop(loop)
+}
+
+func (v *videoFile) Seek(position time.Duration) error {
+	if !v.player.seek(position) {
+		return ErrVideoSeek
+	}
+
+	return nil
+}
+
+func (v *videoFile) Pause() {
+	v.player.pause()
+}
+
+func (v *videoFile) Resume() {
+	v.player.resume()
+}
+
+//Position returns -1 when it is unknown
+func (v *videoFile) Position() time.Duration {
+	return queryPosition(v.player.pipeline)
+}
+
+//Duration returns -1 when it is unknown
+func (v *videoFile) Duration() time.Duration {
+	return queryDuration(v.player.pipeline)
+}
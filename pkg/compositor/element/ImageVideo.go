@@ -0,0 +1,209 @@
+package element
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" //register decoder for image sizes
+	_ "image/png"  //register decoder for image sizes
+	"os"
+	"sync"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//VideoImage is a still picture source for slides, avatars or holding cards
+type VideoImage interface {
+	StatefulVideo
+
+	SetImage(location string) error
+	Image() string
+}
+
+type videoImage struct {
+	video
+	player      *player
+	videofilter gstreamer.Element
+	queue       gstreamer.Element
+
+	location   string
+	slotWidth  int
+	slotHeight int
+
+	mutex sync.Mutex
+}
+
+//ImageSize reads the picture header to get its dimensions
+func ImageSize(location string) (int, int, error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return config.Width, config.Height, nil
+}
+
+//NewVideoImage creates a source showing a PNG or JPEG picture
+func NewVideoImage(width int, height int, location string) (VideoImage, error) {
+	imageWidth, imageHeight, err := ImageSize(location)
+	if err != nil {
+		return nil, err
+	}
+
+	uri, err := toURI(location)
+	if err != nil {
+		return nil, err
+	}
+
+	video := &videoImage{location: location}
+
+	player, err := newPlayer(uri, videoIDGenerator, true)
+	if err != nil {
+		return nil, err
+	}
+
+	videosrc, err := newInterVideoSrc(fmt.Sprintf("source_%d", videoIDGenerator), player.videoChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	err = video.initTail(videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	video.videosrc = videosrc
+	video.player = player
+	video.videofilter = videofilter
+	video.queue = queue
+	video.sourceWidth = imageWidth
+	video.sourceHeight = imageHeight
+
+	//frames are counted before the inter channel, intervideosrc repeats the last one
+	video.framesPad, err = player.videosink.GetStaticPad("sink")
+	if err != nil {
+		return nil, err
+	}
+
+	player.onError = func(message string) {
+		video.stats.decodeError()
+		video.emit(video.Name(), EventError, message)
+	}
+
+	videoIDGenerator++
+	video.SetSize(width, height)
+
+	return video, nil
+}
+
+func (v *videoImage) SetPipeline(pipeline gstreamer.Pipeline) error {
+	if err := v.ClearPipeline(); err != nil {
+		return err
+	}
+
+	if !pipeline.Add(v.videosrc) ||
+		!pipeline.Add(v.videofilter) ||
+		!pipeline.Add(v.queue) ||
+		!v.videosrc.Link(v.videofilter) ||
+		!v.videofilter.Link(v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
+	v.pipeline = pipeline
+
+	return nil
+}
+
+func (v *videoImage) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.videosrc.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
+//SetSize scales the picture into a width x height slot following the fit mode
+func (v *videoImage) SetSize(width int, height int) {
+	v.slotWidth = width
+	v.slotHeight = height
+
+	scaledWidth, scaledHeight := v.fitSize(width, height)
+	caps, _ := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), scaledWidth, scaledHeight))
+	v.videofilter.Set("caps", caps)
+	v.player.setSize(scaledWidth, scaledHeight)
+	v.resizeTail(scaledWidth, scaledHeight)
+}
+
+//SetState shows the picture while the compositor plays
+func (v *videoImage) SetState(state gstreamer.GstState) {
+	v.player.setState(state)
+}
+
+//SourcePipeline returns the pipeline decoding the picture
+func (v *videoImage) SourcePipeline() gstreamer.Pipeline {
+	return v.player.pipeline
+}
+
+//SetImage swaps the picture, the source keeps its slot, the last frame is
+//shown until the new picture is decoded
+func (v *videoImage) SetImage(location string) error {
+	imageWidth, imageHeight, err := ImageSize(location)
+	if err != nil {
+		return err
+	}
+
+	uri, err := toURI(location)
+	if err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.location = location
+	v.sourceWidth = imageWidth
+	v.sourceHeight = imageHeight
+	v.SetSize(v.slotWidth, v.slotHeight)
+	v.player.setURI(uri)
+
+	return nil
+}
+
+//Image returns the picture location
+func (v *videoImage) Image() string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.location
+}
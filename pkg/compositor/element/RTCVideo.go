@@ -1,8 +1,14 @@
 package element
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/pion/rtp"
 	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
 	"github.com/vinijabes/gostreamer/pkg/gstreamer"
 )
@@ -13,6 +19,7 @@ const (
 	VideoRTCCodecVP8  VideoRTCCodec = "VP8"
 	VideoRTCCodecVP9  VideoRTCCodec = "VP9"
 	VideoRTCCodecH264 VideoRTCCodec = "H264"
+	VideoRTCCodecAV1  VideoRTCCodec = "AV1"
 	VideoRTCCodecOpus VideoRTCCodec = "opus"
 	VideoRTCCodecG722 VideoRTCCodec = "G722"
 	VideoRTCCodecPCMA VideoRTCCodec = "PCMA"
@@ -22,38 +29,105 @@ const (
 type VideoRTC interface {
 	Video
 	Push(buffer []byte) error
+	PushAt(buffer []byte, capture time.Time) error
+	PushPacket(packet *rtp.Packet) error
+	PushPacketAt(packet *rtp.Packet, capture time.Time) error
+
+	SetLatency(latency int)
+	SetDropOnLatency(drop bool)
+
+	SetQueueLimits(limits QueueLimits) error
+	QueueLimits() QueueLimits
+	Ready() bool
+
+	OnKeyframeRequest(handler KeyframeRequestHandler)
+	SetKeyframeRequestInterval(interval time.Duration)
 }
 
 type videoRTC struct {
 	video
-	inputfilter gstreamer.Element
-	videodepay  gstreamer.Element
-	decodebin   gstreamer.Element
-	videoscale  gstreamer.Element
-	videofilter gstreamer.Element
-	timeoverlay gstreamer.Element
-	queue       gstreamer.Element
+	inputfilter  gstreamer.Element
+	jitterbuffer gstreamer.Element
+	videodepay   gstreamer.Element
+	decodebin    gstreamer.Element
+	videoscale   gstreamer.Element
+	videofilter  gstreamer.Element
+	queue        gstreamer.Element
+	tracker      *rtpTracker
+	codec        VideoRTCCodec
+	payload      int
+	limits       QueueLimits
+	limitsMutex  sync.Mutex
+
+	keyframeHandler  KeyframeRequestHandler
+	keyframeInterval time.Duration
+	keyframeReason   KeyframeReason
+	lastRequest      time.Time
+	keyframeMutex    sync.Mutex
 }
 
-func createInputFilter(codec VideoRTCCodec, id int) (gstreamer.Element, error) {
-	var err error
-	var caps gstreamer.Caps
+//rtcKeyframeInterval is the minimum time between two keyframe requests
+const rtcKeyframeInterval = time.Second
 
-	inputfilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("inputfilter_%d", id))
+//rtcStallTimeout is how long the decoder can go without output while packets
+//arrive before a keyframe is requested
+const rtcStallTimeout = time.Second
+
+//rtcDefaultLatency is the jitterbuffer latency in milliseconds
+const rtcDefaultLatency = 200
+
+//rtcCodec describes how a video codec is carried over RTP and decoded,
+//payload is the dynamic payload type used when none is given
+type rtcCodec struct {
+	encoding  string
+	clockRate int
+	payload   int
+	depay     string
+	decoders  []string
+}
+
+var rtcVideoCodecs = map[VideoRTCCodec]rtcCodec{
+	VideoRTCCodecVP8:  {encoding: "VP8", clockRate: 90000, payload: 96, depay: "rtpvp8depay", decoders: []string{"vp8dec"}},
+	VideoRTCCodecVP9:  {encoding: "VP9", clockRate: 90000, payload: 98, depay: "rtpvp9depay", decoders: []string{"vp9dec"}},
+	VideoRTCCodecH264: {encoding: "H264", clockRate: 90000, payload: 102, depay: "rtph264depay", decoders: []string{"avdec_h264"}},
+	VideoRTCCodecAV1:  {encoding: "AV1", clockRate: 90000, payload: 45, depay: "rtpav1depay", decoders: []string{"dav1ddec", "av1dec"}},
+}
+
+var ErrVideoRTCCodec = errors.New("Unsupported RTC video codec")
+
+//videoCodecFromEncoding returns the codec of an RTP encoding name
+func videoCodecFromEncoding(encoding string) (VideoRTCCodec, bool) {
+	for codec, info := range rtcVideoCodecs {
+		if strings.EqualFold(info.encoding, encoding) {
+			return codec, true
+		}
+	}
+
+	return "", false
+}
+
+//rtpCaps returns the caps of the codec RTP stream with a payload type
+func rtpCaps(codec VideoRTCCodec, payload int) (string, error) {
+	info, ok := rtcVideoCodecs[codec]
+	if !ok {
+		return "", ErrVideoRTCCodec
+	}
+
+	return fmt.Sprintf("application/x-rtp,media=video,clock-rate=%d,encoding-name=%s,payload=%d", info.clockRate, info.encoding, payload), nil
+}
+
+func createInputFilter(codec VideoRTCCodec, payload int, id int) (gstreamer.Element, error) {
+	description, err := rtpCaps(codec, payload)
 	if err != nil {
 		return nil, err
 	}
 
-	switch codec {
-	case VideoRTCCodecVP8:
-		caps, err = gstreamer.NewCapsFromString("application/x-rtp, encoding-name=VP8-DRAFT-IETF-01")
-		break
-	case VideoRTCCodecVP9:
-	case VideoRTCCodecH264:
-		caps, err = gstreamer.NewCapsFromString("application/x-rtp")
-		break
+	caps, err := gstreamer.NewCapsFromString(description)
+	if err != nil {
+		return nil, err
 	}
 
+	inputfilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("inputfilter_%d", id))
 	if err != nil {
 		return nil, err
 	}
@@ -64,32 +138,46 @@ func createInputFilter(codec VideoRTCCodec, id int) (gstreamer.Element, error) {
 }
 
 func createDepay(codec VideoRTCCodec, id int) (gstreamer.Element, error) {
-	switch codec {
-	case VideoRTCCodecVP8:
-		return gstreamer.NewElement("rtpvp8depay", fmt.Sprintf("depay_%d", id))
-	case VideoRTCCodecVP9:
-		return gstreamer.NewElement("rtpvp9depay", fmt.Sprintf("depay_%d", id))
-	case VideoRTCCodecH264:
-		return gstreamer.NewElement("rtph264depay", fmt.Sprintf("depay_%d", id))
+	info, ok := rtcVideoCodecs[codec]
+	if !ok {
+		return nil, ErrVideoRTCCodec
 	}
 
-	return nil, nil
+	return gstreamer.NewElement(info.depay, fmt.Sprintf("depay_%d", id))
 }
 
+//createDecoder uses the first decoder of the codec that is installed
 func createDecoder(codec VideoRTCCodec, id int) (gstreamer.Element, error) {
-	switch codec {
-	case VideoRTCCodecVP8:
-		return gstreamer.NewElement("vp8dec", fmt.Sprintf("decoder_%d", id))
-	case VideoRTCCodecVP9:
-		return gstreamer.NewElement("vp9dec", fmt.Sprintf("decoder_%d", id))
-	case VideoRTCCodecH264:
-		return gstreamer.NewElement("avdec_h264", fmt.Sprintf("decoder_%d", id))
+	info, ok := rtcVideoCodecs[codec]
+	if !ok {
+		return nil, ErrVideoRTCCodec
 	}
 
-	return nil, nil
+	var err error
+	for _, factory := range info.decoders {
+		var decoder gstreamer.Element
+		decoder, err = gstreamer.NewElement(factory, fmt.Sprintf("decoder_%d", id))
+		if err == nil {
+			return decoder, nil
+		}
+	}
+
+	return nil, err
 }
 
+//NewVideoRTC creates a source decoding RTP packets pushed by the application,
+//using the codec default payload type
 func NewVideoRTC(width int, height int, codec VideoRTCCodec) (VideoRTC, error) {
+	info, ok := rtcVideoCodecs[codec]
+	if !ok {
+		return nil, ErrVideoRTCCodec
+	}
+
+	return NewVideoRTCWithPayload(width, height, codec, info.payload)
+}
+
+//NewVideoRTCWithPayload creates a RTC source for a negotiated payload type
+func NewVideoRTCWithPayload(width int, height int, codec VideoRTCCodec, payload int) (VideoRTC, error) {
 	logging.Debug("creating new RTC video src")
 	video := &videoRTC{}
 
@@ -102,15 +190,32 @@ func NewVideoRTC(width int, height int, codec VideoRTCCodec) (VideoRTC, error) {
 
 	videosrc.Set("format", 3)
 	videosrc.Set("is-live", true)
-	videosrc.Set("do-timestamp", true)
+	//buffers are timestamped on push from their capture time
+	videosrc.Set("do-timestamp", false)
+	if err := applyQueueLimits(videosrc, DefaultQueueLimits()); err != nil {
+		logging.Error(err)
+		return nil, err
+	}
 
 	logging.Debug("creating RTC video input capsfilter")
-	inputfilter, err := createInputFilter(codec, videoIDGenerator)
+	inputfilter, err := createInputFilter(codec, payload, videoIDGenerator)
+	if err != nil {
+		logging.Error(err)
+		return nil, err
+	}
+
+	logging.Debug("creating RTC video jitterbuffer")
+	jitterbuffer, err := gstreamer.NewElement("rtpjitterbuffer", fmt.Sprintf("jitterbuffer_%d", videoIDGenerator))
 	if err != nil {
 		logging.Error(err)
 		return nil, err
 	}
 
+	//mode none takes the timing from RTP timestamps only, arrival times are
+	//still used to find late packets
+	jitterbuffer.Set("mode", 0)
+	jitterbuffer.Set("latency", uint32(rtcDefaultLatency))
+
 	logging.Debug("creating RTC video rtp depay")
 	videodepay, err := createDepay(codec, videoIDGenerator)
 	if err != nil {
@@ -139,22 +244,15 @@ func NewVideoRTC(width int, height int, codec VideoRTCCodec) (VideoRTC, error) {
 		return nil, err
 	}
 
-	logging.Debug("creating RTC video output box")
-	videobox, err := gstreamer.NewElement("videobox", fmt.Sprintf("box_%d", videoIDGenerator))
+	logging.Debug("creating RTC video queue")
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
 	if err != nil {
 		logging.Error(err)
 		return nil, err
 	}
 
-	// logging.Debug("creating RTC video timeoverlay")
-	// timeoverlay, err := gstreamer.NewElement("timeoverlay", fmt.Sprintf("timeoverlay_%d", videoIDGenerator))
-	// if err != nil {
-	// 	logging.Error(err)
-	// 	return nil, err
-	// }
-
-	logging.Debug("creating RTC video queue")
-	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	logging.Debug("creating RTC video output tail")
+	err = video.initTail(videoIDGenerator)
 	if err != nil {
 		logging.Error(err)
 		return nil, err
@@ -162,13 +260,19 @@ func NewVideoRTC(width int, height int, codec VideoRTCCodec) (VideoRTC, error) {
 
 	video.videosrc = videosrc
 	video.inputfilter = inputfilter
+	video.jitterbuffer = jitterbuffer
 	video.videodepay = videodepay
 	video.decodebin = decodebin
 	video.videoscale = videoscale
 	video.videofilter = videofilter
-	// video.timeoverlay = timeoverlay
 	video.queue = queue
-	video.videobox = videobox
+	video.tracker = newRTPTracker(rtcVideoCodecs[codec].clockRate)
+	video.codec = codec
+	video.payload = payload
+	video.limits = DefaultQueueLimits()
+	video.keyframeInterval = rtcKeyframeInterval
+	video.keyframeReason = KeyframeStartup
+	video.stats.countEncodedElement(videosrc, "src")
 
 	videoIDGenerator++
 	video.SetSize(width, height)
@@ -177,51 +281,72 @@ func NewVideoRTC(width int, height int, codec VideoRTCCodec) (VideoRTC, error) {
 }
 
 func (v *videoRTC) SetPipeline(pipeline gstreamer.Pipeline) error {
-	if v.pipeline != nil {
-		v.videosrc.Unlink(v.videofilter)
-		v.videofilter.Unlink(v.videobox)
-
-		if !v.pipeline.Remove(v.videosrc) ||
-			!v.pipeline.Remove(v.decodebin) ||
-			!v.pipeline.Remove(v.videoscale) ||
-			!v.pipeline.Remove(v.videofilter) ||
-			// !v.pipeline.Remove(v.timeoverlay) ||
-			!v.pipeline.Remove(v.queue) ||
-			!v.pipeline.Remove(v.videobox) {
-			return ErrVideoSetPipeline
-		}
+	if err := v.ClearPipeline(); err != nil {
+		return err
 	}
 
 	if !pipeline.Add(v.videosrc) ||
 		!pipeline.Add(v.inputfilter) ||
+		!pipeline.Add(v.jitterbuffer) ||
 		!pipeline.Add(v.videodepay) ||
 		!pipeline.Add(v.decodebin) ||
 		!pipeline.Add(v.videoscale) ||
 		!pipeline.Add(v.videofilter) ||
-		// !pipeline.Add(v.timeoverlay) ||
-		!pipeline.Add(v.queue) ||
-		!pipeline.Add(v.videobox) {
+		!pipeline.Add(v.queue) {
 		return ErrVideoSetPipeline
 	}
 
-	fmt.Println("Linking video")
-
 	if !v.videosrc.Link(v.inputfilter) ||
-		!v.inputfilter.Link(v.videodepay) ||
+		!v.inputfilter.Link(v.jitterbuffer) ||
+		!v.jitterbuffer.Link(v.videodepay) ||
 		!v.videodepay.Link(v.decodebin) ||
 		!v.decodebin.Link(v.videoscale) ||
 		!v.videoscale.Link(v.videofilter) ||
-		!v.videofilter.Link(v.queue) ||
-		// !v.timeoverlay.Link(v.queue) ||
-		!v.queue.Link(v.videobox) {
+		!v.videofilter.Link(v.queue) {
 		return ErrVideoLinkingSetPipeline
 	}
 
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
 	v.pipeline = pipeline
 
 	return nil
 }
 
+func (v *videoRTC) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.stats.countEncoded(nil)
+
+	v.videosrc.Unlink(v.inputfilter)
+	v.inputfilter.Unlink(v.jitterbuffer)
+	v.jitterbuffer.Unlink(v.videodepay)
+	v.videodepay.Unlink(v.decodebin)
+	v.decodebin.Unlink(v.videoscale)
+	v.videoscale.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.inputfilter) ||
+		!removeElement(v.pipeline, v.jitterbuffer) ||
+		!removeElement(v.pipeline, v.videodepay) ||
+		!removeElement(v.pipeline, v.decodebin) ||
+		!removeElement(v.pipeline, v.videoscale) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
 func (v *videoRTC) SetSize(width int, height int) {
 	logging.Debug(fmt.Sprintf("setting video(%s) size to (%d, %d)", v.videosrc.GetName(), width, height))
 	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
@@ -230,8 +355,196 @@ func (v *videoRTC) SetSize(width int, height int) {
 	}
 
 	v.videofilter.Set("caps", caps)
+	v.resizeTail(width, height)
 }
 
+//Push pushes a RTP packet timestamped with its arrival time
 func (v *videoRTC) Push(buffer []byte) error {
-	return v.videosrc.Push(buffer)
+	return v.PushAt(buffer, time.Time{})
+}
+
+//PushAt pushes a RTP packet timestamped with its capture time, a zero time
+//means now. The capture time is also the arrival time of the jitter. Packets
+//that aren't RTP or carry another payload type are dropped
+func (v *videoRTC) PushAt(buffer []byte, capture time.Time) error {
+	now := time.Now()
+	if capture.IsZero() {
+		capture = now
+	}
+
+	if rtpPayload(buffer) == nil || buffer[0]>>6 != 2 {
+		v.tracker.drop()
+		return ErrRTPPacket
+	}
+
+	if int(buffer[1]&0x7f) != v.payload {
+		v.tracker.drop()
+		return ErrRTPPayloadType
+	}
+
+	if v.queuePolicy() == OverflowDropNewest && !v.Ready() {
+		v.tracker.drop()
+		return ErrVideoPushFull
+	}
+
+	skipped := v.tracker.update(buffer, capture)
+	v.checkKeyframe(buffer, skipped, now)
+
+	pts := time.Duration(-1)
+	if running := runningTime(v.videosrc); running >= 0 {
+		pts = running - now.Sub(capture)
+		if pts < 0 {
+			pts = 0
+		}
+	}
+
+	if err := flowResult(pushBuffer(v.videosrc, buffer, pts)); err != nil {
+		v.tracker.drop()
+		return err
+	}
+
+	return nil
+}
+
+//PushPacket pushes a pion packet, its Raw bytes are used when they still match
+//the header so received packets aren't marshalled again
+func (v *videoRTC) PushPacket(packet *rtp.Packet) error {
+	return v.PushPacketAt(packet, time.Time{})
+}
+
+func (v *videoRTC) PushPacketAt(packet *rtp.Packet, capture time.Time) error {
+	if packet == nil {
+		v.tracker.drop()
+		return ErrRTPPacket
+	}
+
+	buffer := packet.Raw
+	if !rawMatches(packet) {
+		var err error
+		buffer, err = packet.Marshal()
+		if err != nil {
+			v.tracker.drop()
+			return fmt.Errorf("%w: %v", ErrRTPPacket, err)
+		}
+	}
+
+	return v.PushAt(buffer, capture)
+}
+
+//SetQueueLimits bounds the packets waiting to be depayloaded and sets what
+//happens when the decoder falls behind
+func (v *videoRTC) SetQueueLimits(limits QueueLimits) error {
+	v.limitsMutex.Lock()
+	defer v.limitsMutex.Unlock()
+
+	if err := applyQueueLimits(v.videosrc, limits); err != nil {
+		return err
+	}
+	v.limits = limits
+
+	return nil
+}
+
+//QueueLimits returns the limits the source queue has
+func (v *videoRTC) QueueLimits() QueueLimits {
+	return queueLimits(v.videosrc, v.queuePolicy())
+}
+
+//Ready tells whether the source queue has room, when it is false a push is
+//blocked or dropped depending on the overflow policy
+func (v *videoRTC) Ready() bool {
+	v.limitsMutex.Lock()
+	limits := v.limits
+	v.limitsMutex.Unlock()
+
+	return queueReady(v.videosrc, limits)
+}
+
+func (v *videoRTC) queuePolicy() OverflowPolicy {
+	v.limitsMutex.Lock()
+	defer v.limitsMutex.Unlock()
+
+	return v.limits.Policy
+}
+
+//rawMatches tells whether the packet Raw bytes still carry its header
+func rawMatches(packet *rtp.Packet) bool {
+	raw := packet.Raw
+	if len(raw) < 12 {
+		return false
+	}
+
+	return raw[1]&0x7f == packet.PayloadType&0x7f &&
+		raw[1]&0x80 != 0 == packet.Marker &&
+		binary.BigEndian.Uint16(raw[2:]) == packet.SequenceNumber &&
+		binary.BigEndian.Uint32(raw[4:]) == packet.Timestamp &&
+		binary.BigEndian.Uint32(raw[8:]) == packet.SSRC
+}
+
+//OnKeyframeRequest sets the handler called when the source needs a keyframe,
+//it is called from the Push goroutine and should not block
+func (v *videoRTC) OnKeyframeRequest(handler KeyframeRequestHandler) {
+	v.keyframeMutex.Lock()
+	defer v.keyframeMutex.Unlock()
+
+	v.keyframeHandler = handler
+}
+
+//SetKeyframeRequestInterval sets the minimum time between two requests
+func (v *videoRTC) SetKeyframeRequestInterval(interval time.Duration) {
+	v.keyframeMutex.Lock()
+	defer v.keyframeMutex.Unlock()
+
+	v.keyframeInterval = interval
+}
+
+//checkKeyframe requests a keyframe at startup, after losses or when the
+//decoder stalls, until one arrives
+func (v *videoRTC) checkKeyframe(packet []byte, skipped uint32, now time.Time) {
+	v.keyframeMutex.Lock()
+
+	if isKeyframe(v.codec, rtpPayload(packet)) {
+		v.keyframeReason = ""
+	} else if skipped > 0 {
+		v.keyframeReason = KeyframeLoss
+	} else if v.keyframeReason == "" {
+		since := v.stats.sinceLastFrame()
+		if since >= 0 && now.Sub(time.Now().Add(-since)) > rtcStallTimeout {
+			v.keyframeReason = KeyframeDecodeStall
+			v.stats.decodeError()
+		}
+	}
+
+	reason := v.keyframeReason
+	handler := v.keyframeHandler
+	if reason == "" || handler == nil || now.Sub(v.lastRequest) < v.keyframeInterval {
+		v.keyframeMutex.Unlock()
+		return
+	}
+
+	v.lastRequest = now
+	v.keyframeMutex.Unlock()
+
+	handler(reason)
+}
+
+//SetLatency sets how long the jitterbuffer waits for reordered packets, in milliseconds
+func (v *videoRTC) SetLatency(latency int) {
+	v.jitterbuffer.Set("latency", uint32(latency))
+}
+
+//SetDropOnLatency drops packets that would make the jitterbuffer exceed its latency
+func (v *videoRTC) SetDropOnLatency(drop bool) {
+	v.jitterbuffer.Set("drop-on-latency", drop)
+}
+
+//Stats adds the packet loss, reordering and jitter counters of pushed
+//packets to the source stats, rejected packets count as dropped frames
+func (v *videoRTC) Stats() SourceStats {
+	stats := v.video.Stats()
+	rtp := v.tracker.stats()
+	stats.RTP = &rtp
+	stats.DroppedFrames = rtp.Dropped
+
+	return stats
 }
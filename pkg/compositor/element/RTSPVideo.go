@@ -1,71 +1,230 @@
 package element
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
 	"github.com/vinijabes/gostreamer/pkg/gstreamer"
 )
 
+//VideoRTSP is a RTSP camera source, it reconnects on its own when the camera
+//goes away without affecting the other sources
 type VideoRTSP interface {
-	Video
+	StatefulVideo
+
+	Audio() gstreamer.Element
+
+	SetBackoff(initial time.Duration, max time.Duration)
+	Connected() bool
 }
 
+//videoRTSP runs rtspsrc and decodebin in their own pipeline so their errors
+//only reach its bus, frames are handed over through an inter channel
 type videoRTSP struct {
 	video
+	options RTSPOptions
+
+	source      gstreamer.Pipeline
+	rtspsrc     gstreamer.Element
 	decodebin   gstreamer.Element
-	videoscale  gstreamer.Element
+	convert     gstreamer.Element
+	scale       gstreamer.Element
+	scalefilter gstreamer.Element
+	sink        gstreamer.Element
+
+	audiodecodebin gstreamer.Element
+	audioconvert   gstreamer.Element
+	audioresample  gstreamer.Element
+	audiosink      gstreamer.Element
+	audiosrc       gstreamer.Element
+
 	videofilter gstreamer.Element
-	timeoverlay gstreamer.Element
 	queue       gstreamer.Element
+
+	state      gstreamer.GstState
+	connected  bool
+	retrying   bool
+	attempt    int
+	initial    time.Duration
+	max        time.Duration
+	retryTimer *time.Timer
+	done       chan struct{}
+	fakesinks  []gstreamer.Element
+
+	mutex sync.Mutex
+	//stateMutex orders state changes and reconnections, streaming threads
+	//take mutex while the source pipeline changes state so it can't be held
+	stateMutex sync.Mutex
 }
 
+//RTSPTransport restricts the lower transports rtspsrc tries
+type RTSPTransport string
+
+//RTSP transports
+const (
+	RTSPTransportAny RTSPTransport = ""
+	RTSPTransportUDP RTSPTransport = "udp"
+	RTSPTransportTCP RTSPTransport = "tcp"
+)
+
+//RTSPOptions configures a RTSP source, zero values keep the rtspsrc defaults
+type RTSPOptions struct {
+	Location string
+	//Latency of the jitterbuffer in milliseconds
+	Latency   int
+	Transport RTSPTransport
+	//Username and Password are used for basic or digest authentication,
+	//so credentials don't have to be part of the location
+	Username string
+	Password string
+	//Timeout without UDP packets before trying TCP
+	Timeout time.Duration
+	//TCPTimeout for connecting and receiving over TCP
+	TCPTimeout time.Duration
+	//KeepAlive sends RTSP keep-alive requests, needed by cameras that drop idle sessions
+	KeepAlive bool
+	//Track picks the video stream by its 1 based index in the SDP, 0 takes the first video stream
+	Track int
+	//Audio decodes the first audio stream, add Audio() to the compositor to mix it
+	Audio bool
+	//BackoffInitial and BackoffMax bound the reconnection delay
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+}
+
+var ErrRTSPTransport = errors.New("Unknown RTSP transport")
+
+//Default reconnection backoff
+const (
+	rtspBackoffInitial = time.Second
+	rtspBackoffMax     = 30 * time.Second
+)
+
 func NewVideoRTSP(width int, height int, location string, latency int) (VideoRTSP, error) {
-	video := &videoRTSP{}
-	videosrc, err := gstreamer.NewElement("rtspsrc", fmt.Sprintf("source_%d", videoIDGenerator))
+	return NewVideoRTSPWithOptions(width, height, RTSPOptions{
+		Location:  location,
+		Latency:   latency,
+		KeepAlive: true,
+	})
+}
+
+//NewVideoRTSPWithOptions creates a RTSP source, a gst-rtsp-server test-launch
+//instance is enough to try it locally:
+//
+//test-launch "( videotestsrc is-live=true ! x264enc tune=zerolatency ! rtph264pay name=pay0 pt=96 )"
+func NewVideoRTSPWithOptions(width int, height int, options RTSPOptions) (VideoRTSP, error) {
+	switch options.Transport {
+	case RTSPTransportAny, RTSPTransportUDP, RTSPTransportTCP:
+	default:
+		return nil, ErrRTSPTransport
+	}
+
+	if options.BackoffInitial <= 0 {
+		options.BackoffInitial = rtspBackoffInitial
+	}
+
+	if options.BackoffMax < options.BackoffInitial {
+		options.BackoffMax = rtspBackoffMax
+		if options.BackoffMax < options.BackoffInitial {
+			options.BackoffMax = options.BackoffInitial
+		}
+	}
+
+	id := videoIDGenerator
+	video := &videoRTSP{
+		options: options,
+		state:   gstreamer.GstStateNull,
+		initial: options.BackoffInitial,
+		max:     options.BackoffMax,
+	}
+
+	source, err := gstreamer.NewPipeline(fmt.Sprintf("rtsp_%d", id))
 	if err != nil {
 		return nil, err
 	}
 
-	videosrc.Set("location", location)
-	videosrc.Set("latency", latency)
+	convert, err := gstreamer.NewElement("videoconvert", fmt.Sprintf("rtsp_videoconvert_%d", id))
+	if err != nil {
+		return nil, err
+	}
 
-	decodebin, err := gstreamer.NewElement("decodebin", fmt.Sprintf("decodebin_%d", videoIDGenerator))
+	scale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("rtsp_videoscale_%d", id))
 	if err != nil {
 		return nil, err
 	}
 
-	videoscale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("videoscale_%d", videoIDGenerator))
+	scalefilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("rtsp_videofilter_%d", id))
 	if err != nil {
 		return nil, err
 	}
 
-	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", videoIDGenerator))
+	channel := fmt.Sprintf("rtsp_video_%d", id)
+	sink, err := gstreamer.NewElement("intervideosink", fmt.Sprintf("rtsp_videosink_%d", id))
 	if err != nil {
 		return nil, err
 	}
+	sink.Set("channel", channel)
 
-	videobox, err := gstreamer.NewElement("videobox", fmt.Sprintf("box_%d", videoIDGenerator))
+	videosrc, err := newInterVideoSrc(fmt.Sprintf("source_%d", id), channel)
 	if err != nil {
 		return nil, err
 	}
 
-	timeoverlay, err := gstreamer.NewElement("timeoverlay", fmt.Sprintf("timeoverlay_%d", videoIDGenerator))
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", id))
 	if err != nil {
 		return nil, err
 	}
 
-	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", id))
 	if err != nil {
 		return nil, err
 	}
 
+	err = video.initTail(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !source.Add(convert) ||
+		!source.Add(scale) ||
+		!source.Add(scalefilter) ||
+		!source.Add(sink) {
+		return nil, ErrVideoSetPipeline
+	}
+
+	if !convert.Link(scale) ||
+		!scale.Link(scalefilter) ||
+		!scalefilter.Link(sink) {
+		return nil, ErrVideoLinkingSetPipeline
+	}
+
+	video.source = source
+	video.convert = convert
+	video.scale = scale
+	video.scalefilter = scalefilter
+	video.sink = sink
 	video.videosrc = videosrc
-	video.decodebin = decodebin
-	video.videoscale = videoscale
 	video.videofilter = videofilter
-	video.timeoverlay = timeoverlay
 	video.queue = queue
-	video.videobox = videobox
+
+	//frames are counted before the inter channel, intervideosrc repeats the last one
+	video.framesPad, err = sink.GetStaticPad("sink")
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Audio {
+		if err := video.initAudio(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := video.build(); err != nil {
+		return nil, err
+	}
 
 	videoIDGenerator++
 	video.SetSize(width, height)
@@ -73,66 +232,471 @@ func NewVideoRTSP(width int, height int, location string, latency int) (VideoRTS
 	return video, nil
 }
 
-func (v *videoRTSP) SetPipeline(pipeline gstreamer.Pipeline) error {
-	if v.pipeline != nil {
-		v.videosrc.Unlink(v.videofilter)
-		v.videofilter.Unlink(v.videobox)
+//initAudio creates the audio branch, its output is handed over to the
+//compositor pipeline through an inter channel
+func (v *videoRTSP) initAudio(id int) error {
+	audioconvert, err := gstreamer.NewElement("audioconvert", fmt.Sprintf("rtsp_audioconvert_%d", id))
+	if err != nil {
+		return err
+	}
 
-		if !v.pipeline.Remove(v.videosrc) ||
-			!v.pipeline.Remove(v.videofilter) ||
-			!v.pipeline.Remove(v.videobox) {
-			return ErrVideoSetPipeline
-		}
+	audioresample, err := gstreamer.NewElement("audioresample", fmt.Sprintf("rtsp_audioresample_%d", id))
+	if err != nil {
+		return err
 	}
 
-	if !pipeline.Add(v.videosrc) ||
-		!pipeline.Add(v.decodebin) ||
-		!pipeline.Add(v.videoscale) ||
-		!pipeline.Add(v.videofilter) ||
-		!pipeline.Add(v.timeoverlay) ||
-		!pipeline.Add(v.queue) ||
-		!pipeline.Add(v.videobox) {
+	channel := fmt.Sprintf("rtsp_audio_%d", id)
+	audiosink, err := gstreamer.NewElement("interaudiosink", fmt.Sprintf("rtsp_audiosink_%d", id))
+	if err != nil {
+		return err
+	}
+	audiosink.Set("channel", channel)
+
+	audiosrc, err := gstreamer.NewElement("interaudiosrc", fmt.Sprintf("audiosource_%d", id))
+	if err != nil {
+		return err
+	}
+	audiosrc.Set("channel", channel)
+
+	if !v.source.Add(audioconvert) ||
+		!v.source.Add(audioresample) ||
+		!v.source.Add(audiosink) {
+		return ErrVideoSetPipeline
+	}
+
+	if !audioconvert.Link(audioresample) || !audioresample.Link(audiosink) {
+		return ErrVideoLinkingSetPipeline
+	}
+
+	v.audioconvert = audioconvert
+	v.audioresample = audioresample
+	v.audiosink = audiosink
+	v.audiosrc = audiosrc
+
+	return nil
+}
+
+//build creates the rtspsrc and decodebins of a connection attempt
+func (v *videoRTSP) build() error {
+	rtspsrc, err := gstreamer.NewElement("rtspsrc", fmt.Sprintf("rtspsrc_%d", v.id))
+	if err != nil {
+		return err
+	}
+
+	if err := v.configure(rtspsrc); err != nil {
+		return err
+	}
+
+	decodebin, err := gstreamer.NewElement("decodebin", fmt.Sprintf("decodebin_%d", v.id))
+	if err != nil {
+		return err
+	}
+
+	//elements are kept as soon as they are added so teardown removes them
+	//after a failed attempt
+	if !v.source.Add(rtspsrc) {
 		return ErrVideoSetPipeline
 	}
+	v.rtspsrc = rtspsrc
 
-	v.videosrc.SetOnPadAddedCallback(func(element gstreamer.Element, pad gstreamer.Pad) {
-		fmt.Println("VideoSrc pad-added")
-		sinkpad, err := v.decodebin.GetStaticPad("sink")
+	if !v.source.Add(decodebin) {
+		return ErrVideoSetPipeline
+	}
+	v.decodebin = decodebin
+
+	var audiodecodebin gstreamer.Element
+	if v.audioconvert != nil {
+		audiodecodebin, err = gstreamer.NewElement("decodebin", fmt.Sprintf("audiodecodebin_%d", v.id))
 		if err != nil {
-			fmt.Println(err)
+			return err
 		}
 
-		result := pad.Link(sinkpad)
-		if result != gstreamer.GstPadLinkOk {
-			fmt.Println("Failed to link rtsp pad")
+		if !v.source.Add(audiodecodebin) {
+			return ErrVideoSetPipeline
 		}
-	})
+		v.audiodecodebin = audiodecodebin
 
-	v.decodebin.SetOnPadAddedCallback(func(element gstreamer.Element, pad gstreamer.Pad) {
-		fmt.Println("Decodebin pad-added")
-		sinkpad, err := v.videoscale.GetStaticPad("sink")
-		if err != nil {
-			fmt.Println(err)
+		audiodecodebin.SetOnPadAddedCallback(v.onDecodedPad)
+	}
+
+	rtspsrc.SetOnPadAddedCallback(v.onStreamPad)
+	decodebin.SetOnPadAddedCallback(v.onDecodedPad)
+
+	v.stats.countEncodedElement(decodebin, "sink")
+
+	return nil
+}
+
+//onStreamPad routes rtspsrc streams to their decodebin by media, streams
+//nobody wants are drained
+func (v *videoRTSP) onStreamPad(element gstreamer.Element, pad gstreamer.Pad) {
+	var target gstreamer.Element
+	switch padCapsField(pad, "media") {
+	case "audio":
+		target = v.audiodecodebin
+	case "video", "":
+		if v.wantsTrack(pad) {
+			target = v.decodebin
 		}
+	}
+
+	if target == nil {
+		v.drain(pad)
+		return
+	}
+
+	sinkpad, err := target.GetStaticPad("sink")
+	if err != nil {
+		logging.Error(err)
+		v.drain(pad)
+		return
+	}
 
-		result := pad.Link(sinkpad)
-		if result != gstreamer.GstPadLinkOk {
-			fmt.Println("Failed to link rtsp pad")
+	//a second stream of the same media finds the decodebin already linked
+	if result := pad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+		v.drain(pad)
+	}
+}
+
+//onDecodedPad routes decoded pads by media type
+func (v *videoRTSP) onDecodedPad(element gstreamer.Element, pad gstreamer.Pad) {
+	var target gstreamer.Element
+	switch padMediaType(pad) {
+	case "video/x-raw":
+		target = v.convert
+	case "audio/x-raw":
+		target = v.audioconvert
+	}
+
+	if target == nil {
+		v.drain(pad)
+		return
+	}
+
+	sinkpad, err := target.GetStaticPad("sink")
+	if err != nil {
+		logging.Error(err)
+		v.drain(pad)
+		return
+	}
+
+	if result := pad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+		logging.Error(fmt.Sprintf("Failed to link rtsp pad %s: %d", pad.GetName(), result))
+		v.drain(pad)
+		return
+	}
+
+	if target == v.convert {
+		v.setConnected()
+	}
+}
+
+//configure applies the options to a new rtspsrc
+func (v *videoRTSP) configure(rtspsrc gstreamer.Element) error {
+	options := v.options
+
+	rtspsrc.Set("location", options.Location)
+	rtspsrc.Set("latency", options.Latency)
+	rtspsrc.Set("do-rtsp-keep-alive", options.KeepAlive)
+
+	if options.Transport != RTSPTransportAny {
+		if err := setPropertyString(rtspsrc, "protocols", string(options.Transport)); err != nil {
+			return err
 		}
-	})
+	}
+
+	if options.Username != "" {
+		rtspsrc.Set("user-id", options.Username)
+		rtspsrc.Set("user-pw", options.Password)
+	}
+
+	//rtspsrc timeouts are in microseconds
+	if options.Timeout > 0 {
+		rtspsrc.Set("timeout", int(options.Timeout/time.Microsecond))
+	}
+
+	if options.TCPTimeout > 0 {
+		rtspsrc.Set("tcp-timeout", int(options.TCPTimeout/time.Microsecond))
+	}
+
+	return nil
+}
+
+//wantsTrack tells whether a rtspsrc pad carries the selected video track,
+//pads are named recv_rtp_src_<stream>_<ssrc>_<pt> with a 0 based stream index
+func (v *videoRTSP) wantsTrack(pad gstreamer.Pad) bool {
+	if v.options.Track <= 0 {
+		return true
+	}
+
+	var stream, ssrc, pt int
+	if _, err := fmt.Sscanf(pad.GetName(), "recv_rtp_src_%d_%d_%d", &stream, &ssrc, &pt); err != nil {
+		return false
+	}
+
+	return stream == v.options.Track-1
+}
+
+//drain links a pad nobody consumes to a fakesink, teardown removes it
+func (v *videoRTSP) drain(pad gstreamer.Pad) {
+	fakesink := linkFakesink(v.source, pad)
+	if fakesink == nil {
+		return
+	}
+
+	v.mutex.Lock()
+	v.fakesinks = append(v.fakesinks, fakesink)
+	v.mutex.Unlock()
+}
+
+//teardown takes the rtspsrc, the decodebins and the fakesinks of the last
+//attempt out, the source pipeline must be stopped. Removing them unlinks them
+func (v *videoRTSP) teardown() bool {
+	v.stats.countEncoded(nil)
+
+	v.mutex.Lock()
+	elements := append([]gstreamer.Element{v.rtspsrc, v.decodebin, v.audiodecodebin}, v.fakesinks...)
+	v.fakesinks = nil
+	v.mutex.Unlock()
+
+	removed := true
+	for _, e := range elements {
+		if e != nil && !v.source.Remove(e) {
+			removed = false
+		}
+	}
+
+	v.rtspsrc = nil
+	v.decodebin = nil
+	v.audiodecodebin = nil
+
+	return removed
+}
+
+func (v *videoRTSP) SetPipeline(pipeline gstreamer.Pipeline) error {
+	if err := v.ClearPipeline(); err != nil {
+		return err
+	}
 
-	if !v.videoscale.Link(v.videofilter) ||
-		!v.videofilter.Link(v.timeoverlay) ||
-		!v.timeoverlay.Link(v.videobox) {
+	if !pipeline.Add(v.videosrc) ||
+		!pipeline.Add(v.videofilter) ||
+		!pipeline.Add(v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	if !v.videosrc.Link(v.videofilter) ||
+		!v.videofilter.Link(v.queue) {
 		return ErrVideoLinkingSetPipeline
 	}
 
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
 	v.pipeline = pipeline
 
 	return nil
 }
 
+func (v *videoRTSP) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.videosrc.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
 func (v *videoRTSP) SetSize(width int, height int) {
 	caps, _ := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
 	v.videofilter.Set("caps", caps)
+	v.scalefilter.Set("caps", caps)
+	v.resizeTail(width, height)
+}
+
+//SetState connects while the compositor runs and disconnects when it stops
+func (v *videoRTSP) SetState(state gstreamer.GstState) {
+	v.stateMutex.Lock()
+	defer v.stateMutex.Unlock()
+
+	v.mutex.Lock()
+	v.state = state
+	v.mutex.Unlock()
+
+	v.source.SetState(state)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if state != gstreamer.GstStateNull {
+		if v.done == nil {
+			v.done = make(chan struct{})
+			go watchBus(v.source, v.done, v.handleMessage)
+		}
+		return
+	}
+
+	if v.done != nil {
+		close(v.done)
+		v.done = nil
+	}
+
+	if v.retryTimer != nil {
+		v.retryTimer.Stop()
+		v.retryTimer = nil
+	}
+
+	v.connected = false
+	v.retrying = false
+	v.attempt = 0
+}
+
+//SourcePipeline returns the pipeline receiving the camera, it keeps its
+//elements across reconnections
+func (v *videoRTSP) SourcePipeline() gstreamer.Pipeline {
+	return v.source
+}
+
+//Audio returns the element carrying the camera audio, nil unless the Audio
+//option is set. Add it with Compositor.AddAudio
+func (v *videoRTSP) Audio() gstreamer.Element {
+	return v.audiosrc
+}
+
+//SetBackoff sets the first reconnection delay and its maximum, the delay
+//doubles after every failed attempt
+func (v *videoRTSP) SetBackoff(initial time.Duration, max time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.initial = initial
+	v.max = max
+}
+
+func (v *videoRTSP) Connected() bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.connected
+}
+
+func (v *videoRTSP) handleMessage(message gstreamer.Message) {
+	switch message.GetType() {
+	case gstreamer.MessageError:
+		v.stats.decodeError()
+		v.disconnected(fmt.Sprint(message.GetStructure()))
+	case gstreamer.MessageEOS:
+		v.disconnected("EOS")
+	}
+}
+
+func (v *videoRTSP) setConnected() {
+	v.mutex.Lock()
+	if v.connected {
+		v.mutex.Unlock()
+		return
+	}
+
+	v.connected = true
+	v.attempt = 0
+	v.mutex.Unlock()
+
+	v.setSignalLost(false)
+	v.emit(v.Name(), EventConnected, v.options.Location)
+}
+
+//disconnected schedules a reconnection, errors posted while one is pending are ignored
+func (v *videoRTSP) disconnected(reason string) {
+	v.mutex.Lock()
+	if v.retrying || v.state == gstreamer.GstStateNull {
+		v.mutex.Unlock()
+		return
+	}
+
+	v.connected = false
+	attempt, delay := v.scheduleRetry()
+	v.mutex.Unlock()
+
+	v.setSignalLost(true)
+	v.emit(v.Name(), EventDisconnected, reason)
+	v.emit(v.Name(), EventRetrying, fmt.Sprintf("attempt %d in %s", attempt, delay))
+}
+
+//retry schedules the next attempt after one that couldn't be started
+func (v *videoRTSP) retry() {
+	v.mutex.Lock()
+	if v.retrying || v.state == gstreamer.GstStateNull {
+		v.mutex.Unlock()
+		return
+	}
+
+	attempt, delay := v.scheduleRetry()
+	v.mutex.Unlock()
+
+	v.emit(v.Name(), EventRetrying, fmt.Sprintf("attempt %d in %s", attempt, delay))
+}
+
+//scheduleRetry starts the timer of the next attempt, the mutex must be held
+func (v *videoRTSP) scheduleRetry() (int, time.Duration) {
+	delay := v.backoff()
+	v.retrying = true
+	v.attempt++
+	v.retryTimer = time.AfterFunc(delay, v.reconnect)
+
+	return v.attempt, delay
+}
+
+//backoff returns the delay before the next attempt, the mutex must be held
+func (v *videoRTSP) backoff() time.Duration {
+	delay := v.initial
+	for i := 0; i < v.attempt && delay < v.max; i++ {
+		delay *= 2
+	}
+
+	if delay > v.max {
+		delay = v.max
+	}
+
+	return delay
+}
+
+//reconnect rebuilds the rtspsrc and decodebin and restarts the source pipeline
+func (v *videoRTSP) reconnect() {
+	v.stateMutex.Lock()
+	defer v.stateMutex.Unlock()
+
+	v.mutex.Lock()
+	v.retryTimer = nil
+	v.retrying = false
+	state := v.state
+	v.mutex.Unlock()
+
+	if state == gstreamer.GstStateNull {
+		return
+	}
+
+	v.stats.reconnect()
+	v.source.SetState(gstreamer.GstStateNull)
+	if !v.teardown() {
+		logging.Error(fmt.Sprintf("Failed to tear down %s", v.Name()))
+	}
+
+	if err := v.build(); err != nil {
+		logging.Error(err)
+		v.retry()
+		return
+	}
+
+	v.source.SetState(state)
 }
@@ -0,0 +1,444 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//SlideDeck shows one picture of an ordered list at a time
+type SlideDeck interface {
+	StatefulVideo
+
+	Next() error
+	Prev() error
+	GoTo(slide int) error
+	Current() int
+	Len() int
+
+	SetAutoAdvance(interval time.Duration)
+}
+
+type slideDeck struct {
+	video
+	convert     gstreamer.Element
+	videoscale  gstreamer.Element
+	videofilter gstreamer.Element
+	queue       gstreamer.Element
+
+	slides  []string
+	cache   *slideCache
+	current int
+	changed time.Time
+	advance time.Duration
+
+	state gstreamer.GstState
+	done  chan struct{}
+	mutex sync.Mutex
+}
+
+//slideFrameRate is how many times per second the current slide is pushed
+const slideFrameRate = 10
+
+//slideCacheDistance is how many slides around the current one are kept decoded
+const slideCacheDistance = 2
+
+var (
+	ErrSlideDeckEmpty  = errors.New("Slide deck has no slides")
+	ErrSlideOutOfRange = errors.New("Slide out of range")
+)
+
+//NewSlideDeck creates a source showing the slides in order, every slide is
+//drawn at the slot size following the fit mode
+func NewSlideDeck(width int, height int, slides []string) (SlideDeck, error) {
+	if len(slides) == 0 {
+		return nil, ErrSlideDeckEmpty
+	}
+
+	if _, _, err := ImageSize(slides[0]); err != nil {
+		return nil, err
+	}
+
+	video := &slideDeck{
+		slides: append([]string(nil), slides...),
+		cache:  newSlideCache(),
+		state:  gstreamer.GstStateNull,
+	}
+
+	videosrc, err := gstreamer.NewElement("appsrc", fmt.Sprintf("source_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videosrc.Set("format", 3)
+	videosrc.Set("is-live", true)
+	videosrc.Set("do-timestamp", true)
+
+	convert, err := gstreamer.NewElement("videoconvert", fmt.Sprintf("videoconvert_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videoscale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("videoscale_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	err = video.initTail(videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	video.videosrc = videosrc
+	video.convert = convert
+	video.videoscale = videoscale
+	video.videofilter = videofilter
+	video.queue = queue
+
+	videoIDGenerator++
+	video.SetSize(width, height)
+
+	return video, nil
+}
+
+func (v *slideDeck) SetPipeline(pipeline gstreamer.Pipeline) error {
+	if err := v.ClearPipeline(); err != nil {
+		return err
+	}
+
+	if !pipeline.Add(v.videosrc) ||
+		!pipeline.Add(v.convert) ||
+		!pipeline.Add(v.videoscale) ||
+		!pipeline.Add(v.videofilter) ||
+		!pipeline.Add(v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	if !v.videosrc.Link(v.convert) ||
+		!v.convert.Link(v.videoscale) ||
+		!v.videoscale.Link(v.videofilter) ||
+		!v.videofilter.Link(v.queue) {
+		return ErrVideoLinkingSetPipeline
+	}
+
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
+	v.pipeline = pipeline
+
+	return nil
+}
+
+func (v *slideDeck) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.videosrc.Unlink(v.convert)
+	v.convert.Unlink(v.videoscale)
+	v.videoscale.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.convert) ||
+		!removeElement(v.pipeline, v.videoscale) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
+//SetSize draws the slides again at the width x height slot size following
+//the fit mode, the frames already fill the slot so the tail adds no border
+func (v *slideDeck) SetSize(width int, height int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,format=RGBA,width=%d,height=%d,framerate=%d/1", width, height, slideFrameRate))
+	if err != nil {
+		logging.Error(err)
+		return
+	}
+	v.videosrc.Set("caps", caps)
+	v.cache.resize(width, height, v.fit)
+
+	scaledWidth, scaledHeight := v.fitSize(width, height)
+	caps, _ = gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), scaledWidth, scaledHeight))
+	v.videofilter.Set("caps", caps)
+	v.resizeTail(scaledWidth, scaledHeight)
+
+	v.cache.prefetch(v.slides, v.current)
+}
+
+//SetState pushes the current slide while the compositor is running
+func (v *slideDeck) SetState(state gstreamer.GstState) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.state = state
+	if state == gstreamer.GstStateNull {
+		if v.done != nil {
+			close(v.done)
+			v.done = nil
+		}
+		return
+	}
+
+	if v.done == nil {
+		v.done = make(chan struct{})
+		v.changed = time.Now()
+		go v.run(v.done)
+	}
+}
+
+func (v *slideDeck) Next() error {
+	v.mutex.Lock()
+	slide := v.current + 1
+	v.mutex.Unlock()
+
+	return v.GoTo(slide)
+}
+
+func (v *slideDeck) Prev() error {
+	v.mutex.Lock()
+	slide := v.current - 1
+	v.mutex.Unlock()
+
+	return v.GoTo(slide)
+}
+
+//GoTo shows a slide by its zero based index
+func (v *slideDeck) GoTo(slide int) error {
+	if slide < 0 || slide >= len(v.slides) {
+		return ErrSlideOutOfRange
+	}
+
+	v.mutex.Lock()
+	changed := v.current != slide
+	v.current = slide
+	v.changed = time.Now()
+	v.mutex.Unlock()
+
+	if changed {
+		v.cache.prefetch(v.slides, slide)
+		v.emit(v.Name(), EventSlide, strconv.Itoa(slide))
+	}
+
+	return nil
+}
+
+func (v *slideDeck) Current() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.current
+}
+
+func (v *slideDeck) Len() int {
+	return len(v.slides)
+}
+
+//SetAutoAdvance moves to the next slide every interval, going back to the
+//first one after the last, zero disables it
+func (v *slideDeck) SetAutoAdvance(interval time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.advance = interval
+	v.changed = time.Now()
+}
+
+//run pushes the current slide at the deck frame rate and auto advances
+func (v *slideDeck) run(done chan struct{}) {
+	ticker := time.NewTicker(time.Second / slideFrameRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		v.mutex.Lock()
+		current := v.current
+		due := v.advance > 0 && time.Since(v.changed) >= v.advance
+		v.mutex.Unlock()
+
+		if due {
+			current = (current + 1) % len(v.slides)
+			v.GoTo(current)
+		}
+
+		//SetSize can't change the frame size between the lookup and the push
+		v.mutex.Lock()
+		frame, err := v.cache.frame(v.slides, current)
+		if err == nil {
+			err = v.videosrc.Push(frame)
+		}
+		v.mutex.Unlock()
+
+		if err != nil {
+			logging.Error(err)
+		}
+	}
+}
+
+//slideCache keeps the slides around the current one decoded as raw frames
+//of the slot size
+type slideCache struct {
+	width  int
+	height int
+	fit    FitMode
+	center int
+	frames map[int][]byte
+	mutex  sync.Mutex
+}
+
+func newSlideCache() *slideCache {
+	return &slideCache{
+		frames: make(map[int][]byte),
+	}
+}
+
+//resize drops the frames drawn at another size
+func (c *slideCache) resize(width int, height int, fit FitMode) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.width == width && c.height == height && c.fit == fit {
+		return
+	}
+
+	c.width = width
+	c.height = height
+	c.fit = fit
+	c.frames = make(map[int][]byte)
+}
+
+//frame returns a decoded slide, decoding it if it isn't cached
+func (c *slideCache) frame(slides []string, slide int) ([]byte, error) {
+	c.mutex.Lock()
+	frame, ok := c.frames[slide]
+	width, height, fit := c.width, c.height, c.fit
+	c.mutex.Unlock()
+
+	if ok {
+		return frame, nil
+	}
+
+	frame, err := decodeSlide(slides[slide], width, height, fit)
+	if err != nil {
+		return nil, err
+	}
+
+	//the deck may have moved away or been resized while the slide was decoding
+	c.mutex.Lock()
+	if c.near(slide) && c.width == width && c.height == height && c.fit == fit {
+		c.frames[slide] = frame
+	}
+	c.mutex.Unlock()
+
+	return frame, nil
+}
+
+//near tells whether a slide is kept around the current one, the mutex must be held
+func (c *slideCache) near(slide int) bool {
+	return slide >= c.center-slideCacheDistance && slide <= c.center+slideCacheDistance
+}
+
+//prefetch decodes the slides around slide in the background and drops the far
+//ones, it gives up when the deck moves to another slide
+func (c *slideCache) prefetch(slides []string, slide int) {
+	c.mutex.Lock()
+	c.center = slide
+	for cached := range c.frames {
+		if !c.near(cached) {
+			delete(c.frames, cached)
+		}
+	}
+	c.mutex.Unlock()
+
+	go func() {
+		for distance := 0; distance <= slideCacheDistance; distance++ {
+			for _, near := range []int{slide + distance, slide - distance} {
+				if near < 0 || near >= len(slides) {
+					continue
+				}
+
+				c.mutex.Lock()
+				moved := c.center != slide
+				c.mutex.Unlock()
+				if moved {
+					return
+				}
+
+				if _, err := c.frame(slides, near); err != nil {
+					logging.Error(err)
+				}
+			}
+		}
+	}()
+}
+
+//decodeSlide draws a picture into a width x height RGBA frame following the
+//fit mode, on a black background
+func decodeSlide(location string, width int, height int, fit FitMode) ([]byte, error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	picture, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(frame, frame.Bounds(), image.NewUniform(color.Black), image.ZP, draw.Src)
+
+	bounds := picture.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		draw.Draw(frame, frame.Bounds(), picture, bounds.Min, draw.Over)
+		return frame.Pix, nil
+	}
+
+	scaledWidth, scaledHeight, borders := fitPicture(fit, bounds.Dx(), bounds.Dy(), width, height)
+	left := -borders[borderLeft]
+	top := -borders[borderTop]
+	for y := 0; y < scaledHeight; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/scaledHeight
+		for x := 0; x < scaledWidth; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/scaledWidth
+			frame.Set(left+x, top+y, picture.At(sx, sy))
+		}
+	}
+
+	return frame.Pix, nil
+}
@@ -8,6 +8,7 @@ import (
 
 type VideoTest interface {
 	Video
+	SetPattern(pattern int)
 }
 
 type videoTest struct {
@@ -33,7 +34,7 @@ func NewVideoTest(width int, height int) (VideoTest, error) {
 		return nil, err
 	}
 
-	videobox, err := gstreamer.NewElement("videobox", fmt.Sprintf("box_%d", videoIDGenerator))
+	err = video.initTail(videoIDGenerator)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +42,6 @@ func NewVideoTest(width int, height int) (VideoTest, error) {
 	video.videosrc = videosrc
 	video.videofilter = videofilter
 	video.queue = queue
-	video.videobox = videobox
 
 	videoIDGenerator++
 	video.SetSize(width, height)
@@ -50,33 +50,54 @@ func NewVideoTest(width int, height int) (VideoTest, error) {
 }
 
 func (v *videoTest) SetPipeline(pipeline gstreamer.Pipeline) error {
-	if v.pipeline != nil {
-		v.videosrc.Unlink(v.videofilter)
-		v.videofilter.Unlink(v.videobox)
-
-		if !v.pipeline.Remove(v.videosrc) ||
-			!v.pipeline.Remove(v.videofilter) ||
-			!v.pipeline.Remove(v.videobox) {
-			return ErrVideoSetPipeline
-		}
+	if err := v.ClearPipeline(); err != nil {
+		return err
 	}
 
 	if !pipeline.Add(v.videosrc) ||
 		!pipeline.Add(v.videofilter) ||
 		!pipeline.Add(v.queue) ||
-		!pipeline.Add(v.videobox) ||
 		!v.videosrc.Link(v.videofilter) ||
-		!v.videofilter.Link(v.queue) ||
-		!v.queue.Link(v.videobox) {
+		!v.videofilter.Link(v.queue) {
 		return ErrVideoSetPipeline
 	}
 
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
 	v.pipeline = pipeline
 
 	return nil
 }
 
+func (v *videoTest) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.videosrc.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
 func (v *videoTest) SetSize(width int, height int) {
 	caps, _ := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
 	v.videofilter.Set("caps", caps)
+	v.resizeTail(width, height)
+}
+
+//SetPattern sets the videotestsrc pattern, 0 is SMPTE bars
+func (v *videoTest) SetPattern(pattern int) {
+	v.videosrc.Set("pattern", pattern)
 }
@@ -0,0 +1,248 @@
+package element
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//VideoUDP is a plain RTP over UDP source described by an SDP
+type VideoUDP interface {
+	Video
+	Port() int
+	Codec() VideoRTCCodec
+}
+
+type videoUDP struct {
+	video
+	stream       *sdpStream
+	jitterbuffer gstreamer.Element
+	demux        gstreamer.Element
+	videodepay   gstreamer.Element
+	decoder      gstreamer.Element
+	videoscale   gstreamer.Element
+	videofilter  gstreamer.Element
+	queue        gstreamer.Element
+
+	//fakesinks drain the payload types nobody decodes, they are added from
+	//streaming threads and removed with the source
+	fakesinks     []gstreamer.Element
+	fakesinkMutex sync.Mutex
+}
+
+//NewVideoUDP creates a source listening for the RTP video stream described by
+//an SDP, latency is the jitterbuffer latency in milliseconds. A local sender:
+//
+//gst-launch-1.0 videotestsrc is-live=true ! vp8enc deadline=1 ! rtpvp8pay pt=96 ! udpsink host=127.0.0.1 port=5004
+//
+//matches "m=video 5004 RTP/AVP 96" with "a=rtpmap:96 VP8/90000"
+func NewVideoUDP(width int, height int, sdp string, latency int) (VideoUDP, error) {
+	stream, err := parseSDP(sdp)
+	if err != nil {
+		return nil, err
+	}
+
+	video := &videoUDP{stream: stream}
+
+	videosrc, err := gstreamer.NewElement("udpsrc", fmt.Sprintf("source_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	caps, err := gstreamer.NewCapsFromString(stream.caps())
+	if err != nil {
+		return nil, err
+	}
+
+	videosrc.Set("port", stream.port)
+	videosrc.Set("caps", caps)
+	if stream.multicast() {
+		videosrc.Set("address", stream.address)
+	}
+
+	jitterbuffer, err := gstreamer.NewElement("rtpjitterbuffer", fmt.Sprintf("jitterbuffer_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+	jitterbuffer.Set("latency", uint32(latency))
+
+	demux, err := gstreamer.NewElement("rtpptdemux", fmt.Sprintf("ptdemux_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videodepay, err := createDepay(stream.codec, videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := createDecoder(stream.codec, videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	videoscale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("videoscale_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("videofilter_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := gstreamer.NewElement("queue", fmt.Sprintf("queue_%d", videoIDGenerator))
+	if err != nil {
+		return nil, err
+	}
+
+	err = video.initTail(videoIDGenerator)
+	if err != nil {
+		return nil, err
+	}
+
+	video.videosrc = videosrc
+	video.jitterbuffer = jitterbuffer
+	video.demux = demux
+	video.videodepay = videodepay
+	video.decoder = decoder
+	video.videoscale = videoscale
+	video.videofilter = videofilter
+	video.queue = queue
+	video.stats.countEncodedElement(videosrc, "src")
+
+	videoIDGenerator++
+	video.SetSize(width, height)
+
+	return video, nil
+}
+
+//NewVideoUDPFromFile reads the SDP from a file
+func NewVideoUDPFromFile(width int, height int, location string, latency int) (VideoUDP, error) {
+	sdp, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVideoUDP(width, height, string(sdp), latency)
+}
+
+func (v *videoUDP) SetPipeline(pipeline gstreamer.Pipeline) error {
+	if err := v.ClearPipeline(); err != nil {
+		return err
+	}
+
+	if !pipeline.Add(v.videosrc) ||
+		!pipeline.Add(v.jitterbuffer) ||
+		!pipeline.Add(v.demux) ||
+		!pipeline.Add(v.videodepay) ||
+		!pipeline.Add(v.decoder) ||
+		!pipeline.Add(v.videoscale) ||
+		!pipeline.Add(v.videofilter) ||
+		!pipeline.Add(v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.demux.SetOnPadAddedCallback(func(element gstreamer.Element, pad gstreamer.Pad) {
+		if pad.GetName() != fmt.Sprintf("src_%d", v.stream.payload) {
+			if fakesink := linkFakesink(pipeline, pad); fakesink != nil {
+				v.fakesinkMutex.Lock()
+				v.fakesinks = append(v.fakesinks, fakesink)
+				v.fakesinkMutex.Unlock()
+			}
+			return
+		}
+
+		sinkpad, err := v.videodepay.GetStaticPad("sink")
+		if err != nil {
+			logging.Error(err)
+			return
+		}
+
+		if result := pad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+			logging.Error(fmt.Sprintf("Failed to link payload %d: %d", v.stream.payload, result))
+		}
+	})
+
+	if !v.videosrc.Link(v.jitterbuffer) ||
+		!v.jitterbuffer.Link(v.demux) ||
+		!v.videodepay.Link(v.decoder) ||
+		!v.decoder.Link(v.videoscale) ||
+		!v.videoscale.Link(v.videofilter) ||
+		!v.videofilter.Link(v.queue) {
+		return ErrVideoLinkingSetPipeline
+	}
+
+	if err := v.addTail(pipeline, v.queue); err != nil {
+		return err
+	}
+
+	v.pipeline = pipeline
+
+	return nil
+}
+
+func (v *videoUDP) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	v.stats.countEncoded(nil)
+
+	v.videosrc.Unlink(v.jitterbuffer)
+	v.jitterbuffer.Unlink(v.demux)
+	v.demux.Unlink(v.videodepay)
+	v.videodepay.Unlink(v.decoder)
+	v.decoder.Unlink(v.videoscale)
+	v.videoscale.Unlink(v.videofilter)
+	v.videofilter.Unlink(v.queue)
+
+	if !v.removeTail(v.pipeline, v.queue) ||
+		!removeElement(v.pipeline, v.videosrc) ||
+		!removeElement(v.pipeline, v.jitterbuffer) ||
+		!removeElement(v.pipeline, v.demux) ||
+		!removeElement(v.pipeline, v.videodepay) ||
+		!removeElement(v.pipeline, v.decoder) ||
+		!removeElement(v.pipeline, v.videoscale) ||
+		!removeElement(v.pipeline, v.videofilter) ||
+		!removeElement(v.pipeline, v.queue) {
+		return ErrVideoSetPipeline
+	}
+
+	v.fakesinkMutex.Lock()
+	fakesinks := v.fakesinks
+	v.fakesinks = nil
+	v.fakesinkMutex.Unlock()
+
+	for _, fakesink := range fakesinks {
+		if !removeElement(v.pipeline, fakesink) {
+			return ErrVideoSetPipeline
+		}
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
+func (v *videoUDP) SetSize(width int, height int) {
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
+	if err != nil {
+		logging.Error(err)
+	}
+
+	v.videofilter.Set("caps", caps)
+	v.resizeTail(width, height)
+}
+
+//Port returns the UDP port the source listens on
+func (v *videoUDP) Port() int {
+	return v.stream.port
+}
+
+func (v *videoUDP) Codec() VideoRTCCodec {
+	return v.stream.codec
+}
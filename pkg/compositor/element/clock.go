@@ -0,0 +1,259 @@
+package element
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//ClockMode is what a clock overlay shows
+type ClockMode int
+
+//Clock modes
+const (
+	//ClockWallTime shows the current date and time in the clock timezone
+	ClockWallTime ClockMode = iota
+	//ClockElapsedTime shows the time since the clock was made visible
+	ClockElapsedTime
+)
+
+//DefaultClockFormat is the Go time layout used when none is given
+const DefaultClockFormat = "2006-01-02 15:04:05"
+
+//ClockOptions ...
+type ClockOptions struct {
+	//LabelOptions sets the clock appearance, Text is drawn before the time, e.g. a camera name
+	LabelOptions
+
+	Mode ClockMode
+	//Format is a Go time layout for ClockWallTime
+	Format string
+	//Timezone is an IANA zone name like "America/Sao_Paulo", empty uses the local zone
+	Timezone string
+	//Interval between text updates, defaults to a second
+	Interval time.Duration
+}
+
+//Clock is a text overlay rendering wall clock or elapsed time
+type Clock struct {
+	overlay  gstreamer.Element
+	options  ClockOptions
+	location *time.Location
+	visible  bool
+	started  time.Time
+	done     chan struct{}
+	now      func() time.Time
+
+	mutex sync.Mutex
+}
+
+//NewClock creates a hidden clock overlay, the element still has to be linked by its owner
+func NewClock(name string) (*Clock, error) {
+	overlay, err := gstreamer.NewElement("textoverlay", name)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := &Clock{
+		overlay:  overlay,
+		location: time.Local,
+		now:      time.Now,
+	}
+
+	options := ClockOptions{
+		LabelOptions: DefaultLabelOptions(),
+	}
+	options.Position = LabelTopRight
+
+	err = clock.Configure(options)
+	if err != nil {
+		return nil, err
+	}
+	overlay.Set("silent", true)
+
+	return clock, nil
+}
+
+func newSourceClock(id int) (*Clock, error) {
+	return NewClock(fmt.Sprintf("clock_%d", id))
+}
+
+//Configure applies every clock option, it fails on unknown timezones
+func (c *Clock) Configure(options ClockOptions) error {
+	location := time.Local
+	if options.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(options.Timezone)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.Format == "" {
+		options.Format = DefaultClockFormat
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = time.Second
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	options.LabelOptions = applyTextOptions(c.overlay, options.LabelOptions)
+	c.options = options
+	c.location = location
+
+	if c.visible {
+		c.stopTicker()
+		c.startTicker()
+	}
+	c.render(c.now())
+
+	return nil
+}
+
+//SetTimeSource replaces time.Now as the clock time, e.g. with a stream or test clock
+func (c *Clock) SetTimeSource(now func() time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+	c.render(now())
+}
+
+//Options ...
+func (c *Clock) Options() ClockOptions {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.options
+}
+
+//SetVisible shows or hides the clock, elapsed time restarts each time it is shown
+func (c *Clock) SetVisible(visible bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if visible == c.visible {
+		return
+	}
+
+	c.visible = visible
+	c.overlay.Set("silent", !visible)
+
+	if visible {
+		c.started = c.now()
+		c.render(c.started)
+		c.startTicker()
+	} else {
+		c.stopTicker()
+	}
+}
+
+//Visible ...
+func (c *Clock) Visible() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.visible
+}
+
+//Text returns the text being rendered
+func (c *Clock) Text() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.format(c.now())
+}
+
+//Raw ...
+func (c *Clock) Raw() gstreamer.Element {
+	return c.overlay
+}
+
+//start restarts the ticker of a visible clock stopped by stop
+func (c *Clock) start() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.visible && c.done == nil {
+		c.render(c.now())
+		c.startTicker()
+	}
+}
+
+//stop releases the ticker without changing the visibility the user chose
+func (c *Clock) stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stopTicker()
+}
+
+func (c *Clock) startTicker() {
+	done := make(chan struct{})
+	c.done = done
+
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				c.mutex.Lock()
+				//a tick racing stopTicker must not render once more
+				if c.done == done {
+					c.render(c.now())
+				}
+				c.mutex.Unlock()
+			}
+		}
+	}(c.options.Interval)
+}
+
+func (c *Clock) stopTicker() {
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+}
+
+func (c *Clock) render(now time.Time) {
+	c.overlay.Set("text", c.format(now))
+}
+
+func (c *Clock) format(now time.Time) string {
+	var value string
+	switch c.options.Mode {
+	case ClockElapsedTime:
+		elapsed := time.Duration(0)
+		if !c.started.IsZero() {
+			elapsed = now.Sub(c.started)
+		}
+		value = formatElapsed(elapsed)
+	default:
+		value = now.In(c.location).Format(c.options.Format)
+	}
+
+	if c.options.Text == "" {
+		return value
+	}
+
+	return c.options.Text + " " + value
+}
+
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+
+	return fmt.Sprintf("%d:%02d:%02d", hours, minutes, d/time.Second)
+}
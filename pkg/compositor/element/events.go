@@ -0,0 +1,69 @@
+package element
+
+import (
+	"sync"
+	"time"
+)
+
+//EventType ...
+type EventType string
+
+//Source event types
+const (
+	EventEOS   EventType = "eos"
+	EventError EventType = "error"
+	EventSlide EventType = "slide"
+	EventStats EventType = "stats"
+
+	EventConnected    EventType = "connected"
+	EventDisconnected EventType = "disconnected"
+	EventRetrying     EventType = "retrying"
+
+	EventPlaceholderShown  EventType = "placeholder-shown"
+	EventPlaceholderHidden EventType = "placeholder-hidden"
+)
+
+//Event is something that happened to a single source
+type Event struct {
+	Type    EventType
+	Source  string
+	Time    time.Time
+	Message string
+	//Stats is only set on EventStats
+	Stats *SourceStats
+}
+
+//EventHandler ...
+type EventHandler func(Event)
+
+//eventEmitter fans source events out to every registered handler
+type eventEmitter struct {
+	handlers []EventHandler
+	mutex    sync.Mutex
+}
+
+//OnEvent registers an event handler, handlers are called from GStreamer or watcher goroutines
+func (e *eventEmitter) OnEvent(handler EventHandler) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.handlers = append(e.handlers, handler)
+}
+
+func (e *eventEmitter) emit(source string, eventType EventType, message string) {
+	e.mutex.Lock()
+	handlers := make([]EventHandler, len(e.handlers))
+	copy(handlers, e.handlers)
+	e.mutex.Unlock()
+
+	event := Event{
+		Type:    eventType,
+		Source:  source,
+		Time:    time.Now(),
+		Message: message,
+	}
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,300 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//FallbackOptions configures the slate shown while a source has no signal
+type FallbackOptions struct {
+	//Location is the placeholder picture path
+	Location string
+	//Timeout without buffers before the placeholder is shown
+	Timeout time.Duration
+	//Text is drawn over the placeholder when not empty, e.g. "Reconnecting..."
+	Text string
+}
+
+//fallback mixes the live branch over a frozen placeholder picture and hides
+//the live branch whenever it stops producing buffers
+type fallback struct {
+	options FallbackOptions
+
+	filesrc gstreamer.Element
+	decoder gstreamer.Element
+	convert gstreamer.Element
+	scale   gstreamer.Element
+	filter  gstreamer.Element
+	text    gstreamer.Element
+	freeze  gstreamer.Element
+	mixer   gstreamer.Element
+
+	livePad        gstreamer.Pad
+	placeholderPad gstreamer.Pad
+
+	active bool
+	forced bool
+	done   chan struct{}
+
+	//onChange is called outside the mutex whenever the placeholder is shown or hidden
+	onChange func(active bool)
+
+	mutex sync.Mutex
+}
+
+const (
+	defaultFallbackTimeout = 2 * time.Second
+	minFallbackPoll        = 100 * time.Millisecond
+)
+
+var ErrVideoFallbackLinked = errors.New("Fallback must be set before the video is added to a pipeline")
+
+func newFallback(options FallbackOptions, id int) (*fallback, error) {
+	if options.Timeout <= 0 {
+		options.Timeout = defaultFallbackTimeout
+	}
+
+	filesrc, err := gstreamer.NewElement("filesrc", fmt.Sprintf("fallback_source_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	filesrc.Set("location", options.Location)
+
+	decoder, err := gstreamer.NewElement("decodebin", fmt.Sprintf("fallback_decoder_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	convert, err := gstreamer.NewElement("videoconvert", fmt.Sprintf("fallback_convert_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	scale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("fallback_scale_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("fallback_filter_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := gstreamer.NewElement("textoverlay", fmt.Sprintf("fallback_text_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	text.Set("text", options.Text)
+	text.Set("silent", options.Text == "")
+	text.Set("halignment", textHAlignCenter)
+	text.Set("valignment", textVAlignCenter)
+	text.Set("shaded-background", true)
+
+	freeze, err := gstreamer.NewElement("imagefreeze", fmt.Sprintf("fallback_freeze_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	mixer, err := gstreamer.NewElement("compositor", fmt.Sprintf("fallback_mixer_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := mixer.GetPadTemplate("sink_%u")
+	if err != nil {
+		return nil, err
+	}
+
+	placeholderPad, err := mixer.RequestPad(template, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	placeholderPad.Set("zorder", uint32(0))
+
+	livePad, err := mixer.RequestPad(template, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	livePad.Set("zorder", uint32(1))
+
+	f := &fallback{
+		options:        options,
+		filesrc:        filesrc,
+		decoder:        decoder,
+		convert:        convert,
+		scale:          scale,
+		filter:         filter,
+		text:           text,
+		freeze:         freeze,
+		mixer:          mixer,
+		livePad:        livePad,
+		placeholderPad: placeholderPad,
+	}
+	f.setActive(true)
+
+	return f, nil
+}
+
+func (f *fallback) elements() []gstreamer.Element {
+	return []gstreamer.Element{f.filesrc, f.decoder, f.convert, f.scale, f.filter, f.text, f.freeze, f.mixer}
+}
+
+func (f *fallback) setSize(width int, height int) {
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,width=%d,height=%d", width, height))
+	if err != nil {
+		logging.Error(err)
+		return
+	}
+
+	f.filter.Set("caps", caps)
+}
+
+//add puts the placeholder branch in the pipeline, the live pad is fed by the src pad of live
+func (f *fallback) add(pipeline gstreamer.Pipeline, live gstreamer.Element) bool {
+	for _, e := range f.elements() {
+		if !pipeline.Add(e) {
+			return false
+		}
+	}
+
+	f.decoder.SetOnPadAddedCallback(func(element gstreamer.Element, pad gstreamer.Pad) {
+		sinkpad, err := f.convert.GetStaticPad("sink")
+		if err != nil {
+			logging.Error(err)
+			return
+		}
+
+		if result := pad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+			logging.Error(fmt.Sprintf("Failed to link fallback decoder pad: %d", result))
+		}
+	})
+
+	if !f.filesrc.Link(f.decoder) ||
+		!f.convert.Link(f.scale) ||
+		!f.scale.Link(f.filter) ||
+		!f.filter.Link(f.text) ||
+		!f.text.Link(f.freeze) {
+		return false
+	}
+
+	freezepad, err := f.freeze.GetStaticPad("src")
+	if err != nil || freezepad.Link(f.placeholderPad) != gstreamer.GstPadLinkOk {
+		return false
+	}
+
+	livepad, err := live.GetStaticPad("src")
+	if err != nil {
+		return false
+	}
+
+	return livepad.Link(f.livePad) == gstreamer.GstPadLinkOk
+}
+
+func (f *fallback) remove(pipeline gstreamer.Pipeline) bool {
+	f.stop()
+
+	for _, e := range f.elements() {
+		if !removeElement(pipeline, e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//watch shows the placeholder whenever the source decoded no frame for the
+//timeout, sinceLastFrame is -1 until the first one
+func (f *fallback) watch(sinceLastFrame func() time.Duration) {
+	f.mutex.Lock()
+	if f.done != nil {
+		f.mutex.Unlock()
+		return
+	}
+	done := make(chan struct{})
+	f.done = done
+	f.mutex.Unlock()
+
+	interval := f.options.Timeout / 4
+	if interval < minFallbackPoll {
+		interval = minFallbackPoll
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			since := sinceLastFrame()
+			f.setActive(f.isForced() || since < 0 || since > f.options.Timeout)
+		}
+	}()
+}
+
+func (f *fallback) stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.done != nil {
+		close(f.done)
+		f.done = nil
+	}
+}
+
+//force shows the placeholder even while buffers flow, for sources that know
+//they lost their signal before their output stops
+func (f *fallback) force(forced bool) {
+	f.mutex.Lock()
+	f.forced = forced
+	f.mutex.Unlock()
+
+	if forced {
+		f.setActive(true)
+	}
+}
+
+func (f *fallback) isForced() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.forced
+}
+
+func (f *fallback) setActive(active bool) {
+	f.mutex.Lock()
+	changed := f.active != active
+	if changed {
+		logging.Debug(fmt.Sprintf("%s placeholder active: %t", f.mixer.GetName(), active))
+	}
+
+	f.active = active
+	if active {
+		f.livePad.Set("alpha", float32(0))
+	} else {
+		f.livePad.Set("alpha", float32(1))
+	}
+	onChange := f.onChange
+	f.mutex.Unlock()
+
+	if changed && onChange != nil {
+		onChange(active)
+	}
+}
+
+//Active reports whether the placeholder is being shown
+func (f *fallback) Active() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.active
+}
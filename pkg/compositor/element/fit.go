@@ -0,0 +1,48 @@
+package element
+
+//FitMode tells how a source picture is scaled into its slot
+type FitMode int
+
+//Fit mode constants
+const (
+	//FitFill stretches the picture to the slot size
+	FitFill FitMode = iota
+	//FitContain scales the picture inside the slot keeping its aspect ratio, the rest is bordered
+	FitContain
+	//FitCover scales the picture over the whole slot keeping its aspect ratio, the rest is cropped
+	FitCover
+)
+
+//border indexes into the video border arrays
+const (
+	borderTop = iota
+	borderRight
+	borderBottom
+	borderLeft
+)
+
+//fitPicture returns the size a sourceWidth x sourceHeight picture is scaled to
+//and the videobox borders that bring it back to width x height
+func fitPicture(mode FitMode, sourceWidth int, sourceHeight int, width int, height int) (int, int, [4]int) {
+	var borders [4]int
+	if mode == FitFill || sourceWidth <= 0 || sourceHeight <= 0 || width <= 0 || height <= 0 {
+		return width, height, borders
+	}
+
+	scaledWidth, scaledHeight := width, height
+	byWidth := width*sourceHeight <= height*sourceWidth
+	if byWidth == (mode == FitContain) {
+		scaledHeight = sourceHeight * width / sourceWidth
+	} else {
+		scaledWidth = sourceWidth * height / sourceHeight
+	}
+
+	dx := scaledWidth - width
+	dy := scaledHeight - height
+	borders[borderLeft] = dx / 2
+	borders[borderRight] = dx - dx/2
+	borders[borderTop] = dy / 2
+	borders[borderBottom] = dy - dy/2
+
+	return scaledWidth, scaledHeight, borders
+}
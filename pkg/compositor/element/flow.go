@@ -0,0 +1,33 @@
+package element
+
+import "errors"
+
+//GstFlowReturn values returned when pushing buffers
+const (
+	flowOK       = 0
+	flowFlushing = -2
+	flowEOS      = -3
+	flowError    = -5
+)
+
+var (
+	ErrVideoPush         = errors.New("Failed to push buffer")
+	ErrVideoPushFlushing = errors.New("Source is flushing, buffer dropped")
+	ErrVideoPushEOS      = errors.New("Source reached EOS, buffer dropped")
+	ErrRTPPacket         = errors.New("Invalid RTP packet")
+	ErrRTPPayloadType    = errors.New("Unexpected RTP payload type")
+)
+
+//flowResult turns a GstFlowReturn into an error, nil when the push succeeded
+func flowResult(flow int) error {
+	switch flow {
+	case flowOK:
+		return nil
+	case flowFlushing:
+		return ErrVideoPushFlushing
+	case flowEOS:
+		return ErrVideoPushEOS
+	}
+
+	return ErrVideoPush
+}
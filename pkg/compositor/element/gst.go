@@ -0,0 +1,538 @@
+package element
+
+/*
+#cgo pkg-config: gstreamer-1.0
+#include <stdlib.h>
+#include <gst/gst.h>
+
+static gboolean gocompositor_seek(void *element, gint64 position) {
+	return gst_element_seek_simple(GST_ELEMENT(element), GST_FORMAT_TIME,
+		GST_SEEK_FLAG_FLUSH | GST_SEEK_FLAG_KEY_UNIT, position);
+}
+
+//gocompositor_set_property returns 1 when the property doesn't exist or
+//isn't writable and 2 when value can't be converted to its type
+static gint gocompositor_set_property(void *element, const gchar *name, const gchar *value) {
+	GParamSpec *spec = g_object_class_find_property(G_OBJECT_GET_CLASS(element), name);
+	if (spec == NULL || !(spec->flags & G_PARAM_WRITABLE)) {
+		return 1;
+	}
+
+	GValue v = G_VALUE_INIT;
+	g_value_init(&v, spec->value_type);
+	if (!gst_value_deserialize(&v, value)) {
+		g_value_unset(&v);
+		return 2;
+	}
+
+	g_object_set_property(G_OBJECT(element), name, &v);
+	g_value_unset(&v);
+
+	return 0;
+}
+
+//gocompositor_parse_bin parses desc into a bin inside wrapper and ghosts its
+//unlinked src pad, it returns the error message or NULL
+static gchar *gocompositor_parse_bin(void *wrapper, const gchar *desc) {
+	GError *error = NULL;
+	GstElement *parsed = gst_parse_bin_from_description(desc, TRUE, &error);
+
+	//recoverable errors, like an unknown property, still return a bin
+	if (error != NULL) {
+		gchar *message = g_strdup(error->message);
+		g_error_free(error);
+		if (parsed != NULL) {
+			gst_object_unref(parsed);
+		}
+		return message;
+	}
+	if (parsed == NULL) {
+		return g_strdup("empty description");
+	}
+
+	GstPad *src = gst_element_get_static_pad(parsed, "src");
+	if (src == NULL) {
+		gst_object_unref(parsed);
+		return g_strdup("no unlinked src pad");
+	}
+
+	gst_bin_add(GST_BIN(wrapper), parsed);
+	gst_element_add_pad(GST_ELEMENT(wrapper), gst_ghost_pad_new("src", src));
+	gst_object_unref(src);
+
+	return NULL;
+}
+
+static gint64 gocompositor_query_position(void *element) {
+	gint64 position = -1;
+	if (!gst_element_query_position(GST_ELEMENT(element), GST_FORMAT_TIME, &position)) {
+		return -1;
+	}
+	return position;
+}
+
+static gint64 gocompositor_query_duration(void *element) {
+	gint64 duration = -1;
+	if (!gst_element_query_duration(GST_ELEMENT(element), GST_FORMAT_TIME, &duration)) {
+		return -1;
+	}
+	return duration;
+}
+static gint64 gocompositor_running_time(void *element) {
+	GstClock *clock = gst_element_get_clock(GST_ELEMENT(element));
+	if (clock == NULL) {
+		return -1;
+	}
+
+	GstClockTime now = gst_clock_get_time(clock);
+	gst_object_unref(clock);
+
+	return now - gst_element_get_base_time(GST_ELEMENT(element));
+}
+
+static gint gocompositor_push(void *element, const void *data, gsize size, gint64 pts) {
+	GstFlowReturn ret = GST_FLOW_ERROR;
+	GstBuffer *buffer = gst_buffer_new_allocate(NULL, size, NULL);
+
+	gst_buffer_fill(buffer, 0, data, size);
+	if (pts >= 0) {
+		GST_BUFFER_PTS(buffer) = pts;
+		GST_BUFFER_DTS(buffer) = pts;
+	}
+
+	g_signal_emit_by_name(element, "push-buffer", buffer, &ret);
+	gst_buffer_unref(buffer);
+
+	return ret;
+}
+
+static guint64 gocompositor_get_uint64(void *element, const gchar *name) {
+	guint64 value = 0;
+	if (g_object_class_find_property(G_OBJECT_GET_CLASS(element), name) != NULL) {
+		g_object_get(G_OBJECT(element), name, &value, NULL);
+	}
+	return value;
+}
+
+static gint64 gocompositor_query_latency(void *element) {
+	GstQuery *query = gst_query_new_latency();
+	GstClockTime min = GST_CLOCK_TIME_NONE;
+
+	if (gst_element_query(GST_ELEMENT(element), query)) {
+		gst_query_parse_latency(query, NULL, &min, NULL);
+	}
+	gst_query_unref(query);
+
+	return GST_CLOCK_TIME_IS_VALID(min) ? (gint64)min : -1;
+}
+
+typedef struct {
+	gint64 buffers;
+	gint64 bytes;
+	gint64 last;
+} gocompositor_counter;
+
+static GstPadProbeReturn gocompositor_count(GstPad *pad, GstPadProbeInfo *info, gpointer data) {
+	gocompositor_counter *counter = data;
+	GstBuffer *buffer = GST_PAD_PROBE_INFO_BUFFER(info);
+
+	__atomic_add_fetch(&counter->buffers, 1, __ATOMIC_RELAXED);
+	__atomic_add_fetch(&counter->bytes, (gint64)gst_buffer_get_size(buffer), __ATOMIC_RELAXED);
+	__atomic_store_n(&counter->last, g_get_monotonic_time(), __ATOMIC_RELAXED);
+
+	return GST_PAD_PROBE_OK;
+}
+
+//the probe owns its counter, it's freed once the probe is removed and its callback returned
+static gocompositor_counter *gocompositor_counter_new(void *pad, gulong *id) {
+	gocompositor_counter *counter = g_new0(gocompositor_counter, 1);
+	*id = gst_pad_add_probe(GST_PAD(pad), GST_PAD_PROBE_TYPE_BUFFER, gocompositor_count, counter, g_free);
+	return counter;
+}
+
+static void gocompositor_counter_read(gocompositor_counter *counter, gint64 *buffers, gint64 *bytes, gint64 *since) {
+	gint64 last = __atomic_load_n(&counter->last, __ATOMIC_RELAXED);
+
+	*buffers = __atomic_load_n(&counter->buffers, __ATOMIC_RELAXED);
+	*bytes = __atomic_load_n(&counter->bytes, __ATOMIC_RELAXED);
+	*since = last == 0 ? -1 : g_get_monotonic_time() - last;
+}
+
+static void gocompositor_counter_remove(void *pad, gulong id) {
+	gst_pad_remove_probe(GST_PAD(pad), id);
+}
+
+static void gocompositor_describe_properties(GstElement *element, GString *out, gboolean all, int depth) {
+	guint n;
+	GParamSpec **specs = g_object_class_list_properties(G_OBJECT_GET_CLASS(element), &n);
+
+	for (guint i = 0; i < n; i++) {
+		GParamSpec *spec = specs[i];
+		GValue value = G_VALUE_INIT;
+
+		if (!(spec->flags & G_PARAM_READABLE) || g_str_equal(spec->name, "name") || g_str_equal(spec->name, "parent")) {
+			continue;
+		}
+
+		g_value_init(&value, spec->value_type);
+		g_object_get_property(G_OBJECT(element), spec->name, &value);
+		if (all || !g_param_value_defaults(spec, &value)) {
+			gchar *contents = g_strdup_value_contents(&value);
+			g_string_append_printf(out, "%*s%s=%s\n", depth * 2 + 4, "", spec->name, contents);
+			g_free(contents);
+		}
+		g_value_unset(&value);
+	}
+
+	g_free(specs);
+}
+
+static void gocompositor_describe_links(GstElement *element, GString *out, int depth) {
+	GstIterator *it = gst_element_iterate_src_pads(element);
+	GValue item = G_VALUE_INIT;
+
+	while (gst_iterator_next(it, &item) == GST_ITERATOR_OK) {
+		GstPad *pad = g_value_get_object(&item);
+		GstPad *peer = gst_pad_get_peer(pad);
+
+		if (peer != NULL) {
+			GstElement *parent = gst_pad_get_parent_element(peer);
+			g_string_append_printf(out, "%*s%s -> %s.%s\n", depth * 2 + 4, "", GST_PAD_NAME(pad),
+				parent != NULL ? GST_ELEMENT_NAME(parent) : "?", GST_PAD_NAME(peer));
+
+			if (parent != NULL) {
+				gst_object_unref(parent);
+			}
+			gst_object_unref(peer);
+		}
+		g_value_reset(&item);
+	}
+
+	g_value_unset(&item);
+	gst_iterator_free(it);
+}
+
+static void gocompositor_describe_element(GstElement *element, GString *out, gboolean all, int depth) {
+	GstElementFactory *factory = gst_element_get_factory(element);
+
+	g_string_append_printf(out, "%*s%s (%s)\n", depth * 2, "", GST_ELEMENT_NAME(element),
+		factory != NULL ? GST_OBJECT_NAME(factory) : G_OBJECT_TYPE_NAME(element));
+	gocompositor_describe_properties(element, out, all, depth);
+	gocompositor_describe_links(element, out, depth);
+
+	if (GST_IS_BIN(element)) {
+		GPtrArray *children = g_ptr_array_new_with_free_func(gst_object_unref);
+		GstIterator *it = gst_bin_iterate_elements(GST_BIN(element));
+		GValue item = G_VALUE_INIT;
+
+		while (gst_iterator_next(it, &item) == GST_ITERATOR_OK) {
+			g_ptr_array_add(children, gst_object_ref(g_value_get_object(&item)));
+			g_value_reset(&item);
+		}
+		g_value_unset(&item);
+		gst_iterator_free(it);
+
+		//bins iterate their children last added first
+		for (guint i = children->len; i > 0; i--) {
+			gocompositor_describe_element(g_ptr_array_index(children, i - 1), out, all, depth + 1);
+		}
+		g_ptr_array_free(children, TRUE);
+	}
+}
+
+static char *gocompositor_describe(void *element, gboolean all) {
+	GString *out = g_string_new(NULL);
+	gocompositor_describe_element(GST_ELEMENT(element), out, all, 0);
+	return g_string_free(out, FALSE);
+}
+
+static char *gocompositor_dot(void *bin) {
+	return gst_debug_bin_to_dot_data(GST_BIN(bin), GST_DEBUG_GRAPH_SHOW_ALL);
+}
+typedef struct {
+	GMutex lock;
+	GCond cond;
+	gboolean done;
+} gocompositor_waiter;
+
+static void gocompositor_waiter_signal(gocompositor_waiter *waiter) {
+	g_mutex_lock(&waiter->lock);
+	waiter->done = TRUE;
+	g_cond_signal(&waiter->cond);
+	g_mutex_unlock(&waiter->lock);
+}
+
+static gboolean gocompositor_waiter_wait(gocompositor_waiter *waiter, gint64 timeout) {
+	gint64 end = g_get_monotonic_time() + timeout;
+	gboolean done;
+
+	g_mutex_lock(&waiter->lock);
+	while (!waiter->done && g_cond_wait_until(&waiter->cond, &waiter->lock, end)) {
+	}
+	done = waiter->done;
+	g_mutex_unlock(&waiter->lock);
+
+	return done;
+}
+
+//the probe owns its waiter, it's freed once the probe is removed and its callback returned
+static void gocompositor_waiter_free(gpointer data) {
+	gocompositor_waiter *waiter = data;
+
+	g_mutex_clear(&waiter->lock);
+	g_cond_clear(&waiter->cond);
+	g_free(waiter);
+}
+
+static GstPadProbeReturn gocompositor_blocked(GstPad *pad, GstPadProbeInfo *info, gpointer data) {
+	gocompositor_waiter_signal(data);
+	return GST_PAD_PROBE_OK;
+}
+
+static GstPadProbeReturn gocompositor_dropped(GstPad *pad, GstPadProbeInfo *info, gpointer data) {
+	return GST_PAD_PROBE_DROP;
+}
+
+static GstPadProbeReturn gocompositor_eos(GstPad *pad, GstPadProbeInfo *info, gpointer data) {
+	if (GST_EVENT_TYPE(GST_PAD_PROBE_INFO_EVENT(info)) == GST_EVENT_EOS) {
+		gocompositor_waiter_signal(data);
+	}
+	return GST_PAD_PROBE_OK;
+}
+
+//gocompositor_block_peer blocks the src pad linked into sink, it returns NULL when sink isn't linked
+static GstPad *gocompositor_block_peer(void *sink, gulong *id, gocompositor_waiter **waiter) {
+	GstPad *peer = gst_pad_get_peer(GST_PAD(sink));
+	if (peer == NULL) {
+		return NULL;
+	}
+
+	*waiter = g_new0(gocompositor_waiter, 1);
+	g_mutex_init(&(*waiter)->lock);
+	g_cond_init(&(*waiter)->cond);
+	*id = gst_pad_add_probe(peer, GST_PAD_PROBE_TYPE_BLOCK_DOWNSTREAM, gocompositor_blocked, *waiter, gocompositor_waiter_free);
+
+	return peer;
+}
+
+static GstPad *gocompositor_drop(void *src, gulong *id) {
+	GstPad *pad = gst_object_ref(GST_PAD(src));
+	*id = gst_pad_add_probe(pad, GST_PAD_PROBE_TYPE_BLOCK_DOWNSTREAM, gocompositor_dropped, NULL, NULL);
+	return pad;
+}
+
+static void gocompositor_probe_remove(GstPad *pad, gulong id) {
+	gst_pad_remove_probe(pad, id);
+	gst_object_unref(pad);
+}
+
+static gboolean gocompositor_drain(void *first, void *last, gint64 timeout) {
+	gocompositor_waiter *waiter = g_new0(gocompositor_waiter, 1);
+	g_mutex_init(&waiter->lock);
+	g_cond_init(&waiter->cond);
+
+	gulong id = gst_pad_add_probe(GST_PAD(last), GST_PAD_PROBE_TYPE_EVENT_DOWNSTREAM, gocompositor_eos, waiter, gocompositor_waiter_free);
+	gboolean done = gst_pad_send_event(GST_PAD(first), gst_event_new_eos()) && gocompositor_waiter_wait(waiter, timeout);
+	gst_pad_remove_probe(GST_PAD(last), id);
+
+	return done;
+}
+
+static void gocompositor_release_pad(void *element, void *pad) {
+	gst_element_release_request_pad(GST_ELEMENT(element), GST_PAD(pad));
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+var ErrProperty = errors.New("Invalid element property")
+
+//seekElement does a flushing key unit seek, gostreamer has no seek support
+func seekElement(e gstreamer.Element, position time.Duration) bool {
+	return C.gocompositor_seek(unsafe.Pointer(e.GetElementPointer()), C.gint64(position.Nanoseconds())) != 0
+}
+
+//queryPosition returns -1 when the position is unknown
+func queryPosition(e gstreamer.Element) time.Duration {
+	return time.Duration(C.gocompositor_query_position(unsafe.Pointer(e.GetElementPointer())))
+}
+
+//queryDuration returns -1 when the duration is unknown
+func queryDuration(e gstreamer.Element) time.Duration {
+	return time.Duration(C.gocompositor_query_duration(unsafe.Pointer(e.GetElementPointer())))
+}
+
+//setPropertyString sets a property from its gst-launch text form, converting
+//it to the property type the way gst-launch does
+func setPropertyString(e gstreamer.Element, name string, value string) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	cvalue := C.CString(value)
+	defer C.free(unsafe.Pointer(cvalue))
+
+	switch C.gocompositor_set_property(unsafe.Pointer(e.GetElementPointer()), cname, cvalue) {
+	case 1:
+		return fmt.Errorf("%w: %s has no %s", ErrProperty, e.GetName(), name)
+	case 2:
+		return fmt.Errorf("%w: %s can't be set to %q", ErrProperty, name, value)
+	}
+
+	return nil
+}
+
+//parseBin fills bin with the elements of a gst-launch description, bin gets
+//a src ghost pad for the one left unlinked. The parser error is returned
+func parseBin(bin gstreamer.Bin, desc string) error {
+	cdesc := C.CString(desc)
+	defer C.free(unsafe.Pointer(cdesc))
+
+	message := C.gocompositor_parse_bin(unsafe.Pointer(bin.GetElementPointer()), cdesc)
+	if message != nil {
+		defer C.g_free(C.gpointer(unsafe.Pointer(message)))
+		return errors.New(C.GoString(message))
+	}
+
+	return nil
+}
+
+//runningTime returns the element running time, -1 when it has no clock yet
+func runningTime(e gstreamer.Element) time.Duration {
+	return time.Duration(C.gocompositor_running_time(unsafe.Pointer(e.GetElementPointer())))
+}
+
+//pushBuffer pushes data into an appsrc with a timestamp, a negative pts
+//leaves the buffer untimestamped. It returns the GstFlowReturn
+func pushBuffer(e gstreamer.Element, data []byte, pts time.Duration) int {
+	if len(data) == 0 {
+		return flowError
+	}
+
+	return int(C.gocompositor_push(unsafe.Pointer(e.GetElementPointer()), unsafe.Pointer(&data[0]), C.gsize(len(data)), C.gint64(pts)))
+}
+
+//getUint64 reads a guint64 property, gostreamer only reads 32 bit integers.
+//It returns 0 when the element has no such property
+func getUint64(e gstreamer.Element, name string) uint64 {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	return uint64(C.gocompositor_get_uint64(unsafe.Pointer(e.GetElementPointer()), cname))
+}
+
+//QueryLatency returns the minimum latency of a pipeline or element, -1 when
+//it can't be queried, e.g. before the pipeline is playing
+func QueryLatency(e gstreamer.Element) time.Duration {
+	return time.Duration(C.gocompositor_query_latency(unsafe.Pointer(e.GetElementPointer())))
+}
+
+//DescribePipeline lists the elements of a bin with their links and the
+//properties that aren't at their default value, or all of them
+func DescribePipeline(bin gstreamer.Element, all bool) string {
+	var cAll C.gboolean
+	if all {
+		cAll = 1
+	}
+
+	text := C.gocompositor_describe(unsafe.Pointer(bin.GetElementPointer()), cAll)
+	defer C.g_free(C.gpointer(unsafe.Pointer(text)))
+
+	return C.GoString(text)
+}
+
+//PipelineDot returns the Graphviz graph of a bin, as GST_DEBUG_DUMP_DOT_DIR would write it
+func PipelineDot(bin gstreamer.Element) string {
+	dot := C.gocompositor_dot(unsafe.Pointer(bin.GetElementPointer()))
+	defer C.g_free(C.gpointer(unsafe.Pointer(dot)))
+
+	return C.GoString(dot)
+}
+
+//padCounter counts the buffers going through a pad with a probe, the counting
+//is done in C so no Go code runs in the streaming thread
+type padCounter struct {
+	pad     gstreamer.Pad
+	counter *C.gocompositor_counter
+	id      C.gulong
+}
+
+func newPadCounter(pad gstreamer.Pad) *padCounter {
+	c := &padCounter{pad: pad}
+	c.counter = C.gocompositor_counter_new(unsafe.Pointer(pad.GetPadPointer()), &c.id)
+
+	return c
+}
+
+//read returns the buffers and bytes counted and the time since the last
+//buffer, -1 when none went through
+func (c *padCounter) read() (uint64, uint64, time.Duration) {
+	var buffers, bytes, since C.gint64
+	C.gocompositor_counter_read(c.counter, &buffers, &bytes, &since)
+
+	if since < 0 {
+		return uint64(buffers), uint64(bytes), -1
+	}
+
+	return uint64(buffers), uint64(bytes), time.Duration(since) * time.Microsecond
+}
+
+//remove takes the probe out, the counter can't be read afterwards
+func (c *padCounter) remove() {
+	C.gocompositor_counter_remove(unsafe.Pointer(c.pad.GetPadPointer()), c.id)
+}
+
+//PadProbe holds or drops the data going through a pad until it's removed
+type PadProbe struct {
+	pad    *C.GstPad
+	id     C.gulong
+	waiter *C.gocompositor_waiter
+}
+
+//BlockPeer blocks the src pad linked into sink and waits up to timeout for
+//its streaming thread to be held, so the link can be undone between two
+//buffers. It returns nil when sink isn't linked. Setting the upstream
+//elements to NULL lets the held thread go
+func BlockPeer(sink gstreamer.Pad, timeout time.Duration) *PadProbe {
+	p := &PadProbe{}
+	p.pad = C.gocompositor_block_peer(unsafe.Pointer(sink.GetPadPointer()), &p.id, &p.waiter)
+	if p.pad == nil {
+		return nil
+	}
+
+	C.gocompositor_waiter_wait(p.waiter, C.gint64(timeout/time.Microsecond))
+
+	return p
+}
+
+//DropPad drops what a src pad pushes instead of holding it, so a tee keeps
+//feeding its other branches
+func DropPad(src gstreamer.Pad) *PadProbe {
+	p := &PadProbe{}
+	p.pad = C.gocompositor_drop(unsafe.Pointer(src.GetPadPointer()), &p.id)
+
+	return p
+}
+
+//Remove lets the data through again
+func (p *PadProbe) Remove() {
+	C.gocompositor_probe_remove(p.pad, p.id)
+}
+
+//DrainChain sends EOS into first and waits up to timeout for it to reach
+//last, so the elements between them finish what they hold, like a muxer
+//writing its index. It returns false when the EOS didn't make it
+func DrainChain(first gstreamer.Pad, last gstreamer.Pad, timeout time.Duration) bool {
+	return C.gocompositor_drain(unsafe.Pointer(first.GetPadPointer()), unsafe.Pointer(last.GetPadPointer()), C.gint64(timeout/time.Microsecond)) != 0
+}
+
+//ReleaseRequestPad gives a request pad back to its element, gostreamer can
+//only request them
+func ReleaseRequestPad(e gstreamer.Element, pad gstreamer.Pad) {
+	C.gocompositor_release_pad(unsafe.Pointer(e.GetElementPointer()), unsafe.Pointer(pad.GetPadPointer()))
+}
@@ -0,0 +1,125 @@
+package element
+
+//KeyframeReason tells why a source needs a keyframe
+type KeyframeReason string
+
+//Keyframe request reasons
+const (
+	//KeyframeStartup is sent until the first keyframe arrives
+	KeyframeStartup KeyframeReason = "startup"
+	//KeyframeLoss is sent when packets were lost
+	KeyframeLoss KeyframeReason = "loss"
+	//KeyframeDecodeStall is sent when packets arrive but the decoder outputs no frames
+	KeyframeDecodeStall KeyframeReason = "decode-stall"
+)
+
+//KeyframeRequestHandler is called when a source needs a keyframe, the
+//application should send a PLI or FIR to the sender
+type KeyframeRequestHandler func(reason KeyframeReason)
+
+//rtpPayload strips the header, CSRCs, extension and padding of a RTP packet
+func rtpPayload(packet []byte) []byte {
+	if len(packet) < 12 {
+		return nil
+	}
+
+	offset := 12 + 4*int(packet[0]&0x0f)
+	if packet[0]&0x10 != 0 {
+		if len(packet) < offset+4 {
+			return nil
+		}
+		offset += 4 + 4*(int(packet[offset+2])<<8|int(packet[offset+3]))
+	}
+
+	end := len(packet)
+	if packet[0]&0x20 != 0 && end > 0 {
+		end -= int(packet[end-1])
+	}
+
+	if offset >= end {
+		return nil
+	}
+
+	return packet[offset:end]
+}
+
+//isKeyframe tells whether a RTP payload starts or carries a keyframe
+func isKeyframe(codec VideoRTCCodec, payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	switch codec {
+	case VideoRTCCodecVP8:
+		return isVP8Keyframe(payload)
+	case VideoRTCCodecVP9:
+		//not inter predicted and beginning of a frame
+		return payload[0]&0x40 == 0 && payload[0]&0x08 != 0
+	case VideoRTCCodecH264:
+		return isH264Keyframe(payload)
+	case VideoRTCCodecAV1:
+		//first packet of a new coded video sequence
+		return payload[0]&0x08 != 0
+	}
+
+	return false
+}
+
+func isVP8Keyframe(payload []byte) bool {
+	//only the start of partition 0 has the frame header
+	if payload[0]&0x10 == 0 || payload[0]&0x07 != 0 {
+		return false
+	}
+
+	offset := 1
+	if payload[0]&0x80 != 0 {
+		if len(payload) < 2 {
+			return false
+		}
+
+		extension := payload[1]
+		offset++
+		if extension&0x80 != 0 {
+			if len(payload) <= offset {
+				return false
+			}
+			if payload[offset]&0x80 != 0 {
+				offset++
+			}
+			offset++
+		}
+		if extension&0x40 != 0 {
+			offset++
+		}
+		if extension&0x30 != 0 {
+			offset++
+		}
+	}
+
+	return len(payload) > offset && payload[offset]&0x01 == 0
+}
+
+func isH264Keyframe(payload []byte) bool {
+	isKey := func(nal byte) bool {
+		nalType := nal & 0x1f
+		return nalType == 5 || nalType == 7
+	}
+
+	switch payload[0] & 0x1f {
+	case 24:
+		//STAP-A, a list of size prefixed NAL units
+		for offset := 1; offset+2 < len(payload); {
+			size := int(payload[offset])<<8 | int(payload[offset+1])
+			if isKey(payload[offset+2]) {
+				return true
+			}
+			offset += 2 + size
+		}
+		return false
+	case 28:
+		//FU-A, the start fragment has the NAL type
+		return len(payload) > 1 && payload[1]&0x80 != 0 && isKey(payload[1])
+	}
+
+	return isKey(payload[0])
+}
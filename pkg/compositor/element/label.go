@@ -0,0 +1,187 @@
+package element
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//LabelPosition is the label placement relative to the source slot
+type LabelPosition int
+
+//Label positions
+const (
+	LabelTopLeft LabelPosition = iota
+	LabelTopCenter
+	LabelTopRight
+	LabelCenterLeft
+	LabelCenter
+	LabelCenterRight
+	LabelBottomLeft
+	LabelBottomCenter
+	LabelBottomRight
+)
+
+//textoverlay halignment and valignment enum values
+const (
+	textHAlignLeft   = 0
+	textHAlignCenter = 1
+	textHAlignRight  = 2
+
+	textVAlignBottom = 1
+	textVAlignTop    = 2
+	textVAlignCenter = 4
+)
+
+//LabelOptions ...
+type LabelOptions struct {
+	Text string
+	Font string
+	Size int
+	//Color is ARGB, e.g. 0xFFFFFFFF for opaque white
+	Color uint32
+
+	Background bool
+	//BackgroundOpacity goes from 0 to 255
+	BackgroundOpacity uint32
+
+	Position LabelPosition
+	OffsetX  int
+	OffsetY  int
+}
+
+//Label is a text overlay drawn inside a source branch, so it follows the source slot
+type Label struct {
+	overlay gstreamer.Element
+	options LabelOptions
+	visible bool
+
+	mutex sync.Mutex
+}
+
+//DefaultLabelOptions ...
+func DefaultLabelOptions() LabelOptions {
+	return LabelOptions{
+		Font:              "Sans",
+		Size:              18,
+		Color:             0xFFFFFFFF,
+		Background:        true,
+		BackgroundOpacity: 80,
+		Position:          LabelBottomLeft,
+		OffsetX:           8,
+		OffsetY:           8,
+	}
+}
+
+func newLabel(id int) (*Label, error) {
+	overlay, err := gstreamer.NewElement("textoverlay", fmt.Sprintf("label_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	label := &Label{
+		overlay: overlay,
+	}
+	label.Configure(DefaultLabelOptions())
+	label.SetVisible(false)
+
+	return label, nil
+}
+
+//Configure applies every label option, a zero Font or Size keeps the default
+func (l *Label) Configure(options LabelOptions) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.options = applyTextOptions(l.overlay, options)
+	l.overlay.Set("text", l.options.Text)
+}
+
+//SetText updates the label text, it can be called while the compositor is running
+func (l *Label) SetText(text string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.options.Text = text
+	l.overlay.Set("text", text)
+}
+
+//Text ...
+func (l *Label) Text() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.options.Text
+}
+
+//Options ...
+func (l *Label) Options() LabelOptions {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.options
+}
+
+//SetVisible ...
+func (l *Label) SetVisible(visible bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.visible = visible
+	l.overlay.Set("silent", !visible)
+}
+
+//Visible ...
+func (l *Label) Visible() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.visible
+}
+
+//applyTextOptions sets the text appearance on a textoverlay based element and
+//returns the options with defaults filled in
+func applyTextOptions(overlay gstreamer.Element, options LabelOptions) LabelOptions {
+	defaults := DefaultLabelOptions()
+	if options.Font == "" {
+		options.Font = defaults.Font
+	}
+
+	if options.Size <= 0 {
+		options.Size = defaults.Size
+	}
+
+	overlay.Set("font-desc", fmt.Sprintf("%s %d", options.Font, options.Size))
+	overlay.Set("color", options.Color)
+	overlay.Set("shaded-background", options.Background)
+	overlay.Set("shading-value", options.BackgroundOpacity)
+	overlay.Set("xpad", options.OffsetX)
+	overlay.Set("ypad", options.OffsetY)
+
+	halign, valign := options.Position.alignment()
+	overlay.Set("halignment", halign)
+	overlay.Set("valignment", valign)
+
+	return options
+}
+
+func (p LabelPosition) alignment() (int, int) {
+	halign := textHAlignLeft
+	switch p {
+	case LabelTopCenter, LabelCenter, LabelBottomCenter:
+		halign = textHAlignCenter
+	case LabelTopRight, LabelCenterRight, LabelBottomRight:
+		halign = textHAlignRight
+	}
+
+	valign := textVAlignTop
+	switch p {
+	case LabelCenterLeft, LabelCenter, LabelCenterRight:
+		valign = textVAlignCenter
+	case LabelBottomLeft, LabelBottomCenter, LabelBottomRight:
+		valign = textVAlignBottom
+	}
+
+	return halign, valign
+}
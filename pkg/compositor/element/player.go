@@ -0,0 +1,427 @@
+package element
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//player decodes an URI in its own pipeline and hands video and audio over to
+//the compositor pipeline through inter channels, so it can be paused, seeked
+//or reach EOS without affecting the other sources
+type player struct {
+	pipeline gstreamer.Pipeline
+	decoder  gstreamer.Element
+
+	videohead    gstreamer.Element
+	videoconvert gstreamer.Element
+	videoscale   gstreamer.Element
+	videofilter  gstreamer.Element
+	videosink    gstreamer.Element
+
+	audioconvert  gstreamer.Element
+	audioresample gstreamer.Element
+	audiosink     gstreamer.Element
+
+	videoChannel string
+	audioChannel string
+
+	loop   bool
+	paused bool
+	state  gstreamer.GstState
+	done   chan struct{}
+
+	onEOS   func()
+	onError func(string)
+
+	mutex sync.Mutex
+}
+
+const busPollInterval = 100 * time.Millisecond
+
+//interVideoTimeout keeps the last frame of an inter channel on screen, the
+//intervideosrc default of 1s turns a paused or stalled player black
+const interVideoTimeout = uint64(math.MaxInt64)
+
+//newInterVideoSrc creates the compositor side of an inter video channel
+func newInterVideoSrc(name string, channel string) (gstreamer.Element, error) {
+	videosrc, err := gstreamer.NewElement("intervideosrc", name)
+	if err != nil {
+		return nil, err
+	}
+	videosrc.Set("channel", channel)
+
+	if err := setPropertyString(videosrc, "timeout", strconv.FormatUint(interVideoTimeout, 10)); err != nil {
+		return nil, err
+	}
+
+	return videosrc, nil
+}
+
+//newPlayer creates the player pipeline, freeze repeats the first decoded frame forever
+func newPlayer(uri string, id int, freeze bool) (*player, error) {
+	pipeline, err := gstreamer.NewPipeline(fmt.Sprintf("player_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := gstreamer.NewElement("uridecodebin", fmt.Sprintf("player_decoder_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	decoder.Set("uri", uri)
+
+	videoconvert, err := gstreamer.NewElement("videoconvert", fmt.Sprintf("player_videoconvert_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	videoscale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("player_videoscale_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	videofilter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("player_videofilter_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	videoChannel := fmt.Sprintf("player_video_%d", id)
+	videosink, err := gstreamer.NewElement("intervideosink", fmt.Sprintf("player_videosink_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	videosink.Set("channel", videoChannel)
+
+	audioconvert, err := gstreamer.NewElement("audioconvert", fmt.Sprintf("player_audioconvert_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	audioresample, err := gstreamer.NewElement("audioresample", fmt.Sprintf("player_audioresample_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	audioChannel := fmt.Sprintf("player_audio_%d", id)
+	audiosink, err := gstreamer.NewElement("interaudiosink", fmt.Sprintf("player_audiosink_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	audiosink.Set("channel", audioChannel)
+
+	p := &player{
+		pipeline:      pipeline,
+		decoder:       decoder,
+		videohead:     videoconvert,
+		videoconvert:  videoconvert,
+		videoscale:    videoscale,
+		videofilter:   videofilter,
+		videosink:     videosink,
+		audioconvert:  audioconvert,
+		audioresample: audioresample,
+		audiosink:     audiosink,
+		videoChannel:  videoChannel,
+		audioChannel:  audioChannel,
+		state:         gstreamer.GstStateNull,
+	}
+
+	if !pipeline.Add(decoder) ||
+		!pipeline.Add(videoconvert) ||
+		!pipeline.Add(videoscale) ||
+		!pipeline.Add(videofilter) ||
+		!pipeline.Add(videosink) ||
+		!pipeline.Add(audioconvert) ||
+		!pipeline.Add(audioresample) ||
+		!pipeline.Add(audiosink) {
+		return nil, ErrVideoSetPipeline
+	}
+
+	if !videoconvert.Link(videoscale) ||
+		!videoscale.Link(videofilter) ||
+		!videofilter.Link(videosink) ||
+		!audioconvert.Link(audioresample) ||
+		!audioresample.Link(audiosink) {
+		return nil, ErrVideoLinkingSetPipeline
+	}
+
+	if freeze {
+		imagefreeze, err := gstreamer.NewElement("imagefreeze", fmt.Sprintf("player_freeze_%d", id))
+		if err != nil {
+			return nil, err
+		}
+
+		if !pipeline.Add(imagefreeze) || !imagefreeze.Link(videoconvert) {
+			return nil, ErrVideoLinkingSetPipeline
+		}
+
+		p.videohead = imagefreeze
+	}
+
+	decoder.SetOnPadAddedCallback(p.onPadAdded)
+
+	return p, nil
+}
+
+//onPadAdded routes decoded pads by media type, anything else is drained
+func (p *player) onPadAdded(element gstreamer.Element, pad gstreamer.Pad) {
+	var target gstreamer.Element
+	switch padMediaType(pad) {
+	case "video/x-raw":
+		target = p.videohead
+	case "audio/x-raw":
+		target = p.audioconvert
+	default:
+		linkFakesink(p.pipeline, pad)
+		return
+	}
+
+	sinkpad, err := target.GetStaticPad("sink")
+	if err != nil {
+		logging.Error(err)
+		return
+	}
+
+	if result := pad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+		logging.Error(fmt.Sprintf("Failed to link player pad %s: %d", pad.GetName(), result))
+		linkFakesink(p.pipeline, pad)
+	}
+}
+
+func (p *player) setSize(width int, height int) {
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,width=%d,height=%d", width, height))
+	if err != nil {
+		logging.Error(err)
+		return
+	}
+
+	p.videofilter.Set("caps", caps)
+}
+
+//setState follows the compositor state, a user pause is kept while the compositor plays
+func (p *player) setState(state gstreamer.GstState) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.state = state
+	if state == gstreamer.GstStatePlaying && p.paused {
+		state = gstreamer.GstStatePaused
+	}
+
+	p.pipeline.SetState(state)
+
+	if p.state == gstreamer.GstStateNull {
+		p.stopWatch()
+	} else {
+		p.startWatch()
+	}
+}
+
+//setURI restarts the player on another URI, keeping its state
+func (p *player) setURI(uri string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.pipeline.SetState(gstreamer.GstStateNull)
+	p.decoder.Set("uri", uri)
+
+	state := p.state
+	if state == gstreamer.GstStatePlaying && p.paused {
+		state = gstreamer.GstStatePaused
+	}
+
+	p.pipeline.SetState(state)
+}
+
+func (p *player) pause() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.paused = true
+	if p.state == gstreamer.GstStatePlaying {
+		p.pipeline.SetState(gstreamer.GstStatePaused)
+	}
+}
+
+func (p *player) resume() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.paused = false
+	if p.state == gstreamer.GstStatePlaying {
+		p.pipeline.SetState(gstreamer.GstStatePlaying)
+	}
+}
+
+func (p *player) setLoop(loop bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.loop = loop
+}
+
+func (p *player) seek(position time.Duration) bool {
+	return seekElement(p.pipeline, position)
+}
+
+func (p *player) startWatch() {
+	if p.done != nil {
+		return
+	}
+
+	p.done = make(chan struct{})
+	go p.watch(p.done)
+}
+
+func (p *player) stopWatch() {
+	if p.done != nil {
+		close(p.done)
+		p.done = nil
+	}
+}
+
+//watch polls the player bus for EOS and errors
+func (p *player) watch(done chan struct{}) {
+	watchBus(p.pipeline, done, func(message gstreamer.Message) {
+		switch message.GetType() {
+		case gstreamer.MessageEOS:
+			p.handleEOS()
+		case gstreamer.MessageError:
+			if p.onError != nil {
+				p.onError(fmt.Sprint(message.GetStructure()))
+			}
+		}
+	})
+}
+
+//watchBus polls a pipeline bus until done is closed, gostreamer has no bus watch
+func watchBus(pipeline gstreamer.Pipeline, done chan struct{}, handle func(gstreamer.Message)) {
+	bus, err := pipeline.GetBus()
+	if err != nil {
+		logging.Error(err)
+		return
+	}
+
+	ticker := time.NewTicker(busPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for bus.HavePending() {
+			message, err := bus.Pop()
+			if err != nil {
+				logging.Error(err)
+				continue
+			}
+
+			handle(message)
+		}
+	}
+}
+
+func (p *player) handleEOS() {
+	if p.onEOS != nil {
+		p.onEOS()
+	}
+
+	p.mutex.Lock()
+	loop := p.loop
+	state := p.state
+	p.mutex.Unlock()
+
+	if loop && !p.seek(0) {
+		p.pipeline.SetState(gstreamer.GstStateNull)
+		p.setState(state)
+	}
+}
+
+//padMediaType returns the media type of a pad current caps, empty if it has none
+func padMediaType(pad gstreamer.Pad) string {
+	caps := pad.GetCurrentCaps()
+	if caps == nil {
+		return ""
+	}
+
+	structure := caps.GetStructure(0)
+	if structure == nil {
+		return ""
+	}
+
+	return structure.GetName()
+}
+
+//padCapsField returns a field of the pad current caps as text, empty if it
+//has none. gostreamer has no field getters so it is read from the caps string
+func padCapsField(pad gstreamer.Pad, field string) string {
+	caps := pad.GetCurrentCaps()
+	if caps == nil {
+		return ""
+	}
+
+	structure := caps.GetStructure(0)
+	if structure == nil {
+		return ""
+	}
+
+	for _, part := range strings.Split(fmt.Sprint(structure), ",") {
+		pair := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(pair) != 2 || pair[0] != field {
+			continue
+		}
+
+		value := pair[1]
+		if strings.HasPrefix(value, "(") {
+			if end := strings.Index(value, ")"); end >= 0 {
+				value = value[end+1:]
+			}
+		}
+
+		return strings.Trim(strings.TrimSuffix(value, ";"), `"`)
+	}
+
+	return ""
+}
+
+//linkFakesink drains a pad nobody consumes so it doesn't stall its pipeline,
+//it returns the fakesink added to the pipeline, nil if none was
+func linkFakesink(pipeline gstreamer.Pipeline, pad gstreamer.Pad) gstreamer.Element {
+	//pads are added from streaming threads
+	id := atomic.AddInt64(&fakesinkIDGenerator, 1) - 1
+	fakesink, err := gstreamer.NewElement("fakesink", fmt.Sprintf("fakesink_%d", id))
+	if err != nil {
+		logging.Error(err)
+		return nil
+	}
+
+	fakesink.Set("sync", false)
+	fakesink.Set("async", false)
+	if !pipeline.Add(fakesink) {
+		logging.Error("Failed to add fakesink")
+		return nil
+	}
+
+	sinkpad, err := fakesink.GetStaticPad("sink")
+	if err != nil {
+		logging.Error(err)
+		return fakesink
+	}
+
+	pad.Link(sinkpad)
+	fakesink.SetState(gstreamer.GstStatePlaying)
+
+	return fakesink
+}
+
+var fakesinkIDGenerator int64
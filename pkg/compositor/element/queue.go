@@ -0,0 +1,93 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//OverflowPolicy tells what an appsrc based source does when its queue is full
+type OverflowPolicy int
+
+//Overflow policies
+const (
+	//OverflowBlock blocks Push until the queue has room
+	OverflowBlock OverflowPolicy = iota
+	//OverflowDropOldest drops queued buffers to make room, it needs GStreamer 1.20
+	OverflowDropOldest
+	//OverflowDropNewest drops the pushed buffer
+	OverflowDropNewest
+)
+
+//QueueLimits bounds the buffers queued in an appsrc, a zero limit is unset
+type QueueLimits struct {
+	MaxBytes uint64
+	MaxTime  time.Duration
+	Policy   OverflowPolicy
+}
+
+//DefaultQueueLimits keeps about a second of high bitrate video
+func DefaultQueueLimits() QueueLimits {
+	return QueueLimits{
+		MaxBytes: 1 << 20,
+		Policy:   OverflowDropNewest,
+	}
+}
+
+var (
+	ErrVideoPushFull  = errors.New("Source queue is full, buffer dropped")
+	ErrOverflowPolicy = errors.New("Overflow policy isn't supported by this GStreamer version")
+)
+
+//applyQueueLimits configures the appsrc queue. Both limits are guint64,
+//gostreamer would set them as a gint and wrap times over about 2s
+func applyQueueLimits(appsrc gstreamer.Element, limits QueueLimits) error {
+	appsrc.Set("block", limits.Policy == OverflowBlock)
+
+	if err := setPropertyString(appsrc, "max-bytes", strconv.FormatUint(limits.MaxBytes, 10)); err != nil {
+		return err
+	}
+
+	if err := setPropertyString(appsrc, "max-time", strconv.FormatUint(uint64(limits.MaxTime), 10)); err != nil {
+		return err
+	}
+
+	//leaky-type needs GStreamer 1.20, dropping the newest buffer is done by
+	//checking the queue before each push
+	if limits.Policy == OverflowDropOldest {
+		if err := setPropertyString(appsrc, "leaky-type", "downstream"); err != nil {
+			return fmt.Errorf("%w: %v", ErrOverflowPolicy, err)
+		}
+	} else {
+		//older versions have no leaky appsrc to undo
+		setPropertyString(appsrc, "leaky-type", "none")
+	}
+
+	return nil
+}
+
+//queueLimits reads the limits back from the appsrc
+func queueLimits(appsrc gstreamer.Element, policy OverflowPolicy) QueueLimits {
+	return QueueLimits{
+		MaxBytes: getUint64(appsrc, "max-bytes"),
+		MaxTime:  time.Duration(getUint64(appsrc, "max-time")),
+		Policy:   policy,
+	}
+}
+
+//queueReady tells whether the appsrc queue is under its limits, it is what
+//need-data and enough-data signal
+func queueReady(appsrc gstreamer.Element, limits QueueLimits) bool {
+	if limits.MaxBytes > 0 && getUint64(appsrc, "current-level-bytes") >= limits.MaxBytes {
+		return false
+	}
+
+	if limits.MaxTime > 0 && getUint64(appsrc, "current-level-time") >= uint64(limits.MaxTime) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,495 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//ParamType is the type of a source parameter
+type ParamType string
+
+//Source parameter types
+const (
+	ParamString   ParamType = "string"
+	ParamInt      ParamType = "int"
+	ParamFloat    ParamType = "float"
+	ParamBool     ParamType = "bool"
+	ParamDuration ParamType = "duration"
+	ParamStrings  ParamType = "strings"
+)
+
+//Param describes a source type parameter, Default is used when an optional
+//parameter is missing
+type Param struct {
+	Name        string
+	Type        ParamType
+	Required    bool
+	Default     interface{}
+	Description string
+}
+
+//Params holds parameters already converted to their schema type
+type Params map[string]interface{}
+
+//SourceFactory creates a source from validated parameters
+type SourceFactory func(width int, height int, params Params) (Video, error)
+
+//SourceType is a named kind of source that can be created from configuration
+type SourceType struct {
+	Name        string
+	Description string
+	Params      []Param
+	Factory     SourceFactory
+}
+
+var (
+	ErrSourceTypeExists  = errors.New("Source type already registered")
+	ErrSourceTypeUnknown = errors.New("Unknown source type")
+	ErrSourceTypeInvalid = errors.New("Source type needs a name and a factory")
+	ErrSourceParam       = errors.New("Invalid source parameter")
+)
+
+var (
+	sourceTypes = make(map[string]SourceType)
+	sourceMutex sync.RWMutex
+)
+
+//RegisterSource adds a source type, names are unique
+func RegisterSource(sourceType SourceType) error {
+	if sourceType.Name == "" || sourceType.Factory == nil {
+		return ErrSourceTypeInvalid
+	}
+
+	for _, param := range sourceType.Params {
+		if param.Default == nil {
+			continue
+		}
+
+		if _, err := param.convert(param.Default); err != nil {
+			return err
+		}
+	}
+
+	sourceMutex.Lock()
+	defer sourceMutex.Unlock()
+
+	if _, ok := sourceTypes[sourceType.Name]; ok {
+		return ErrSourceTypeExists
+	}
+
+	sourceTypes[sourceType.Name] = sourceType
+
+	return nil
+}
+
+//LookupSource returns a registered source type
+func LookupSource(name string) (SourceType, bool) {
+	sourceMutex.RLock()
+	defer sourceMutex.RUnlock()
+
+	sourceType, ok := sourceTypes[name]
+	return sourceType, ok
+}
+
+//SourceTypes returns the registered source types sorted by name
+func SourceTypes() []SourceType {
+	sourceMutex.RLock()
+	defer sourceMutex.RUnlock()
+
+	types := make([]SourceType, 0, len(sourceTypes))
+	for _, sourceType := range sourceTypes {
+		types = append(types, sourceType)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Name < types[j].Name
+	})
+
+	return types
+}
+
+//NewSource creates a source of a registered type from a configuration map,
+//like one decoded from JSON or YAML
+func NewSource(typeName string, width int, height int, config map[string]interface{}) (Video, error) {
+	sourceType, ok := LookupSource(typeName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSourceTypeUnknown, typeName)
+	}
+
+	params, err := sourceType.Validate(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return sourceType.Factory(width, height, params)
+}
+
+//Validate checks a configuration map against the schema and converts its values
+func (t SourceType) Validate(config map[string]interface{}) (Params, error) {
+	known := make(map[string]bool)
+	params := make(Params)
+
+	for _, param := range t.Params {
+		known[param.Name] = true
+
+		value, ok := config[param.Name]
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("%w: %s is required", ErrSourceParam, param.Name)
+			}
+
+			if param.Default == nil {
+				continue
+			}
+			value = param.Default
+		}
+
+		converted, err := param.convert(value)
+		if err != nil {
+			return nil, err
+		}
+
+		params[param.Name] = converted
+	}
+
+	for name := range config {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: %s is unknown for %s sources", ErrSourceParam, name, t.Name)
+		}
+	}
+
+	return params, nil
+}
+
+//convert turns a decoded configuration value into the parameter type
+func (p Param) convert(value interface{}) (interface{}, error) {
+	var converted interface{}
+	var ok bool
+
+	switch p.Type {
+	case ParamString:
+		converted, ok = value.(string)
+	case ParamInt:
+		converted, ok = toInt(value)
+	case ParamFloat:
+		converted, ok = toFloat(value)
+	case ParamBool:
+		converted, ok = value.(bool)
+	case ParamDuration:
+		converted, ok = toDuration(value)
+	case ParamStrings:
+		converted, ok = toStrings(value)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a %s", ErrSourceParam, p.Name, p.Type)
+	}
+
+	return converted, nil
+}
+
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), true
+		}
+	case string:
+		i, err := strconv.Atoi(v)
+		return i, err == nil
+	}
+
+	return 0, false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+//toDuration accepts Go duration strings like "5s" or a number of seconds
+func toDuration(value interface{}) (time.Duration, bool) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		d, err := time.ParseDuration(v)
+		return d, err == nil
+	}
+
+	if seconds, ok := toFloat(value); ok {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	return 0, false
+}
+
+func toStrings(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		strings := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			strings[i] = s
+		}
+		return strings, true
+	}
+
+	return nil, false
+}
+
+//String returns a string parameter, empty if it is missing
+func (p Params) String(name string) string {
+	value, _ := p[name].(string)
+	return value
+}
+
+//Int returns an int parameter, zero if it is missing
+func (p Params) Int(name string) int {
+	value, _ := p[name].(int)
+	return value
+}
+
+//Float returns a float parameter, zero if it is missing
+func (p Params) Float(name string) float64 {
+	value, _ := p[name].(float64)
+	return value
+}
+
+//Bool returns a bool parameter, false if it is missing
+func (p Params) Bool(name string) bool {
+	value, _ := p[name].(bool)
+	return value
+}
+
+//Duration returns a duration parameter, zero if it is missing
+func (p Params) Duration(name string) time.Duration {
+	value, _ := p[name].(time.Duration)
+	return value
+}
+
+//Strings returns a string list parameter, nil if it is missing
+func (p Params) Strings(name string) []string {
+	value, _ := p[name].([]string)
+	return value
+}
+
+var overflowPolicies = map[string]OverflowPolicy{
+	"block":       OverflowBlock,
+	"drop-oldest": OverflowDropOldest,
+	"drop-newest": OverflowDropNewest,
+}
+
+func init() {
+	builtins := []SourceType{
+		{
+			Name:        "test",
+			Description: "videotestsrc test pattern",
+			Params: []Param{
+				{Name: "pattern", Type: ParamInt, Default: 0, Description: "videotestsrc pattern number"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				video, err := NewVideoTest(width, height)
+				if err != nil {
+					return nil, err
+				}
+
+				video.SetPattern(params.Int("pattern"))
+				return video, nil
+			},
+		},
+		{
+			Name:        "element",
+			Description: "any element producing raw video",
+			Params: []Param{
+				{Name: "factory", Type: ParamString, Required: true, Description: "element factory name"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				return NewVideo(width, height, params.String("factory"))
+			},
+		},
+		{
+			Name:        "description",
+			Description: "gst-launch style pipeline description",
+			Params: []Param{
+				{Name: "description", Type: ParamString, Required: true, Description: "linear element chain"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				return NewVideoFromDescription(params.String("description"), width, height)
+			},
+		},
+		{
+			Name:        "rtsp",
+			Description: "RTSP camera or server",
+			Params: []Param{
+				{Name: "location", Type: ParamString, Required: true, Description: "rtsp:// URL"},
+				{Name: "latency", Type: ParamInt, Default: 200, Description: "jitter buffer latency in milliseconds"},
+				{Name: "transport", Type: ParamString, Default: "", Description: "udp or tcp, empty tries both"},
+				{Name: "username", Type: ParamString, Description: "user for basic or digest authentication"},
+				{Name: "password", Type: ParamString, Description: "password for basic or digest authentication"},
+				{Name: "timeout", Type: ParamDuration, Description: "timeout without UDP packets before trying TCP"},
+				{Name: "tcp-timeout", Type: ParamDuration, Description: "TCP connection and receive timeout"},
+				{Name: "keep-alive", Type: ParamBool, Default: true, Description: "send RTSP keep-alive requests"},
+				{Name: "track", Type: ParamInt, Default: 0, Description: "1 based video stream index, 0 takes the first video stream"},
+				{Name: "audio", Type: ParamBool, Default: false, Description: "decode the first audio stream"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				return NewVideoRTSPWithOptions(width, height, RTSPOptions{
+					Location:   params.String("location"),
+					Latency:    params.Int("latency"),
+					Transport:  RTSPTransport(params.String("transport")),
+					Username:   params.String("username"),
+					Password:   params.String("password"),
+					Timeout:    params.Duration("timeout"),
+					TCPTimeout: params.Duration("tcp-timeout"),
+					KeepAlive:  params.Bool("keep-alive"),
+					Track:      params.Int("track"),
+					Audio:      params.Bool("audio"),
+				})
+			},
+		},
+		{
+			Name:        "rtc",
+			Description: "RTP packets pushed by the application",
+			Params: []Param{
+				{Name: "codec", Type: ParamString, Default: string(VideoRTCCodecVP8), Description: "VP8, VP9, H264 or AV1"},
+				{Name: "payload", Type: ParamInt, Description: "negotiated payload type, the codec default when missing"},
+				{Name: "latency", Type: ParamInt, Default: rtcDefaultLatency, Description: "jitter buffer latency in milliseconds"},
+				{Name: "drop-on-latency", Type: ParamBool, Default: false, Description: "drop packets arriving after the latency"},
+				{Name: "queue-bytes", Type: ParamInt, Default: int(DefaultQueueLimits().MaxBytes), Description: "maximum bytes waiting to be decoded"},
+				{Name: "queue-time", Type: ParamDuration, Default: "0s", Description: "maximum time waiting to be decoded, 0 is unlimited"},
+				{Name: "overflow", Type: ParamString, Default: "drop-newest", Description: "block, drop-oldest or drop-newest"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				codec := VideoRTCCodec(params.String("codec"))
+
+				var video VideoRTC
+				var err error
+				if payload := params.Int("payload"); payload > 0 {
+					video, err = NewVideoRTCWithPayload(width, height, codec, payload)
+				} else {
+					video, err = NewVideoRTC(width, height, codec)
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				policy, ok := overflowPolicies[params.String("overflow")]
+				if !ok {
+					return nil, fmt.Errorf("%w: unknown overflow %s", ErrSourceParam, params.String("overflow"))
+				}
+
+				video.SetLatency(params.Int("latency"))
+				video.SetDropOnLatency(params.Bool("drop-on-latency"))
+				err = video.SetQueueLimits(QueueLimits{
+					MaxBytes: uint64(params.Int("queue-bytes")),
+					MaxTime:  params.Duration("queue-time"),
+					Policy:   policy,
+				})
+				if err != nil {
+					return nil, err
+				}
+				return video, nil
+			},
+		},
+		{
+			Name:        "udp",
+			Description: "plain RTP over UDP described by an SDP",
+			Params: []Param{
+				{Name: "sdp", Type: ParamString, Description: "SDP text"},
+				{Name: "sdp-file", Type: ParamString, Description: "SDP file path, used when sdp is empty"},
+				{Name: "latency", Type: ParamInt, Default: 200, Description: "jitter buffer latency in milliseconds"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				if sdp := params.String("sdp"); sdp != "" {
+					return NewVideoUDP(width, height, sdp, params.Int("latency"))
+				}
+
+				if location := params.String("sdp-file"); location != "" {
+					return NewVideoUDPFromFile(width, height, location, params.Int("latency"))
+				}
+
+				return nil, fmt.Errorf("%w: sdp or sdp-file is required", ErrSourceParam)
+			},
+		},
+		{
+			Name:        "file",
+			Description: "local file or URI playback",
+			Params: []Param{
+				{Name: "uri", Type: ParamString, Required: true, Description: "file path or URI"},
+				{Name: "loop", Type: ParamBool, Default: false, Description: "restart when the file ends"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				video, err := NewVideoFile(width, height, params.String("uri"))
+				if err != nil {
+					return nil, err
+				}
+
+				video.SetLoop(params.Bool("loop"))
+				return video, nil
+			},
+		},
+		{
+			Name:        "image",
+			Description: "still PNG or JPEG picture",
+			Params: []Param{
+				{Name: "location", Type: ParamString, Required: true, Description: "picture path"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				return NewVideoImage(width, height, params.String("location"))
+			},
+		},
+		{
+			Name:        "slides",
+			Description: "slide deck showing one picture at a time",
+			Params: []Param{
+				{Name: "slides", Type: ParamStrings, Required: true, Description: "ordered picture paths"},
+				{Name: "interval", Type: ParamDuration, Default: "0s", Description: "auto advance interval, 0 disables it"},
+			},
+			Factory: func(width int, height int, params Params) (Video, error) {
+				deck, err := NewSlideDeck(width, height, params.Strings("slides"))
+				if err != nil {
+					return nil, err
+				}
+
+				deck.SetAutoAdvance(params.Duration("interval"))
+				return deck, nil
+			},
+		},
+	}
+
+	for _, sourceType := range builtins {
+		if err := RegisterSource(sourceType); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package element
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+//RTPStats are packet counters of a RTP source, computed as in RFC 3550.
+//Reordered counts packets older than one already pushed and Dropped the
+//packets rejected on push
+type RTPStats struct {
+	Received  uint64
+	Lost      uint64
+	Reordered uint64
+	Dropped   uint64
+	Jitter    time.Duration
+}
+
+//rtpTracker follows the sequence numbers and timestamps of pushed packets
+type rtpTracker struct {
+	clockRate int
+	started   bool
+	baseSeq   uint32
+	maxSeq    uint32
+	received  uint64
+	reordered uint64
+	dropped   uint64
+	jitter    float64
+
+	lastArrival   time.Time
+	lastTimestamp uint32
+
+	mutex sync.Mutex
+}
+
+func newRTPTracker(clockRate int) *rtpTracker {
+	return &rtpTracker{clockRate: clockRate}
+}
+
+//update accounts a packet, it returns how many packets were skipped before
+//it, packets that are too short to be RTP are ignored
+func (t *rtpTracker) update(packet []byte, arrival time.Time) uint32 {
+	if len(packet) < 12 || packet[0]>>6 != 2 {
+		return 0
+	}
+
+	seq := uint32(binary.BigEndian.Uint16(packet[2:]))
+	timestamp := binary.BigEndian.Uint32(packet[4:])
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.received++
+	t.updateJitter(timestamp, arrival)
+
+	if !t.started {
+		t.started = true
+		t.baseSeq = seq
+		t.maxSeq = seq
+		return 0
+	}
+
+	//extend the 16 bit sequence number around the current maximum
+	extended := t.maxSeq&^0xffff | seq
+	if extended+0x8000 < t.maxSeq {
+		extended += 0x10000
+	} else if extended > t.maxSeq+0x8000 && extended >= 0x10000 {
+		extended -= 0x10000
+	}
+
+	if extended <= t.maxSeq {
+		t.reordered++
+		return 0
+	}
+
+	skipped := extended - t.maxSeq - 1
+	t.maxSeq = extended
+
+	return skipped
+}
+
+//updateJitter follows RFC 3550 6.4.1 with the transit difference of two
+//consecutive packets, computed from their arrival interval so nothing is
+//multiplied by an absolute time
+func (t *rtpTracker) updateJitter(timestamp uint32, arrival time.Time) {
+	if t.clockRate <= 0 {
+		return
+	}
+
+	if t.received > 1 {
+		elapsed := arrival.Sub(t.lastArrival)
+		rate := int64(t.clockRate)
+		arrivalUnits := int64(elapsed/time.Second)*rate + int64(elapsed%time.Second)*rate/int64(time.Second)
+
+		//the timestamp difference is signed so it survives the 32 bit wrap
+		d := arrivalUnits - int64(int32(timestamp-t.lastTimestamp))
+		if d < 0 {
+			d = -d
+		}
+		t.jitter += (float64(d) - t.jitter) / 16
+	}
+
+	t.lastArrival = arrival
+	t.lastTimestamp = timestamp
+}
+
+func (t *rtpTracker) drop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.dropped++
+}
+
+func (t *rtpTracker) stats() RTPStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats := RTPStats{
+		Received:  t.received,
+		Reordered: t.reordered,
+		Dropped:   t.dropped,
+	}
+
+	if !t.started {
+		return stats
+	}
+
+	expected := uint64(t.maxSeq-t.baseSeq) + 1
+	if expected > t.received {
+		stats.Lost = expected - t.received
+	}
+
+	if t.clockRate > 0 {
+		stats.Jitter = time.Duration(t.jitter * float64(time.Second) / float64(t.clockRate))
+	}
+
+	return stats
+}
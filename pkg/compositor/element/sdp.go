@@ -0,0 +1,177 @@
+package element
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//sdpStream is the video stream picked from a session description
+type sdpStream struct {
+	address   string
+	port      int
+	payload   int
+	codec     VideoRTCCodec
+	encoding  string
+	clockRate int
+	fmtp      map[string]string
+}
+
+var (
+	ErrSDPNoVideo = errors.New("SDP has no video stream")
+	ErrSDPCodec   = errors.New("SDP video stream has no supported codec")
+	ErrSDPInvalid = errors.New("Invalid SDP")
+)
+
+//parseSDP returns the first video stream with a supported codec
+func parseSDP(sdp string) (*sdpStream, error) {
+	var stream *sdpStream
+	var payloads []int
+	sessionAddress := ""
+	encodings := make(map[int]string)
+	clockRates := make(map[int]int)
+	fmtps := make(map[int]string)
+	inVideo := false
+
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		value := line[2:]
+
+		switch line[0] {
+		case 'm':
+			if stream != nil {
+				inVideo = false
+				continue
+			}
+
+			fields := strings.Fields(value)
+			if len(fields) < 4 || fields[0] != "video" {
+				inVideo = false
+				continue
+			}
+
+			port, err := strconv.Atoi(strings.SplitN(fields[1], "/", 2)[0])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrSDPInvalid, line)
+			}
+
+			for _, field := range fields[3:] {
+				payload, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s", ErrSDPInvalid, line)
+				}
+				payloads = append(payloads, payload)
+			}
+
+			stream = &sdpStream{address: sessionAddress, port: port}
+			inVideo = true
+		case 'c':
+			fields := strings.Fields(value)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("%w: %s", ErrSDPInvalid, line)
+			}
+
+			address := strings.SplitN(fields[2], "/", 2)[0]
+			if stream == nil {
+				sessionAddress = address
+			} else if inVideo {
+				stream.address = address
+			}
+		case 'a':
+			if !inVideo {
+				continue
+			}
+
+			if strings.HasPrefix(value, "rtpmap:") {
+				fields := strings.Fields(strings.TrimPrefix(value, "rtpmap:"))
+				if len(fields) < 2 {
+					continue
+				}
+
+				payload, err := strconv.Atoi(fields[0])
+				if err != nil {
+					continue
+				}
+
+				encoding := strings.Split(fields[1], "/")
+				encodings[payload] = strings.ToUpper(encoding[0])
+				if len(encoding) > 1 {
+					clockRates[payload], _ = strconv.Atoi(encoding[1])
+				}
+			} else if strings.HasPrefix(value, "fmtp:") {
+				fields := strings.SplitN(strings.TrimPrefix(value, "fmtp:"), " ", 2)
+				if len(fields) < 2 {
+					continue
+				}
+
+				payload, err := strconv.Atoi(fields[0])
+				if err != nil {
+					continue
+				}
+
+				fmtps[payload] = fields[1]
+			}
+		}
+	}
+
+	if stream == nil {
+		return nil, ErrSDPNoVideo
+	}
+
+	for _, payload := range payloads {
+		codec, ok := videoCodecFromEncoding(encodings[payload])
+		if !ok {
+			continue
+		}
+
+		stream.payload = payload
+		stream.codec = codec
+		stream.encoding = encodings[payload]
+		stream.clockRate = clockRates[payload]
+		if stream.clockRate == 0 {
+			stream.clockRate = 90000
+		}
+
+		stream.fmtp = make(map[string]string)
+		for _, parameter := range strings.Split(fmtps[payload], ";") {
+			pair := strings.SplitN(strings.TrimSpace(parameter), "=", 2)
+			if len(pair) == 2 && pair[0] != "" {
+				stream.fmtp[pair[0]] = pair[1]
+			}
+		}
+
+		return stream, nil
+	}
+
+	return nil, ErrSDPCodec
+}
+
+//caps returns the RTP caps describing the stream, fmtp parameters are kept as
+//string fields so depayloaders can read things like sprop-parameter-sets
+func (s *sdpStream) caps() string {
+	caps := fmt.Sprintf("application/x-rtp,media=video,payload=%d,clock-rate=%d,encoding-name=%s", s.payload, s.clockRate, s.encoding)
+
+	names := make([]string, 0, len(s.fmtp))
+	for name := range s.fmtp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		caps += fmt.Sprintf(",%s=(string)\"%s\"", name, s.fmtp[name])
+	}
+
+	return caps
+}
+
+//multicast tells whether the stream address is a group udpsrc has to join
+func (s *sdpStream) multicast() bool {
+	ip := net.ParseIP(s.address)
+	return ip != nil && ip.IsMulticast()
+}
@@ -0,0 +1,261 @@
+package element
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//SourceStats is a snapshot of the health of a source
+type SourceStats struct {
+	Source string
+	//Width and Height of the decoded frames, zero before caps are negotiated
+	Width  int
+	Height int
+	//FPS is the decoded frame rate over the last statsWindow
+	FPS float64
+	//Bitrate of the encoded input in bits per second, zero for raw sources
+	Bitrate float64
+	//Frames counts decoded frames and Bytes encoded input bytes
+	Frames uint64
+	Bytes  uint64
+	//SinceLastBuffer is -1 while no frame was decoded
+	SinceLastBuffer time.Duration
+	//DecodeErrors counts errors posted by the source decoding elements
+	DecodeErrors uint64
+	//DroppedFrames counts input the source dropped before decoding
+	DroppedFrames uint64
+	//Reconnects counts the reconnection attempts of network sources
+	Reconnects uint64
+	//RTP has the packet counters of sources fed with RTP, nil for others
+	RTP *RTPStats
+}
+
+//statsWindow is the shortest period rates are computed over
+const statsWindow = time.Second
+
+//sourceStats counts the frames a source decodes and the encoded bytes
+//entering it with pad probes. Probes are removed when their elements leave
+//a pipeline, their counts are kept so the totals never go backwards
+type sourceStats struct {
+	frames  *padCounter
+	encoded *padCounter
+
+	removedFrames uint64
+	removedBytes  uint64
+	decodeErrors  uint64
+	reconnects    uint64
+
+	rate rateWindow
+
+	mutex sync.Mutex
+}
+
+//countFrames probes the pad decoded frames go through
+func (s *sourceStats) countFrames(pad gstreamer.Pad) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removedFrames += removeCounter(s.frames)
+	s.frames = newPadCounter(pad)
+}
+
+//countEncoded probes the pad encoded buffers go through, usually a source
+//pad, nil only removes the current probe
+func (s *sourceStats) countEncoded(pad gstreamer.Pad) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.encoded != nil {
+		_, bytes, _ := s.encoded.read()
+		s.removedBytes += bytes
+		s.encoded.remove()
+		s.encoded = nil
+	}
+
+	if pad != nil {
+		s.encoded = newPadCounter(pad)
+	}
+}
+
+//countEncodedElement probes a static pad of e, failures are only logged as
+//the source works without its bitrate
+func (s *sourceStats) countEncodedElement(e gstreamer.Element, name string) {
+	pad, err := e.GetStaticPad(name)
+	if err != nil {
+		logging.Error(err)
+		return
+	}
+
+	s.countEncoded(pad)
+}
+
+func (s *sourceStats) stopFrames() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removedFrames += removeCounter(s.frames)
+	s.frames = nil
+}
+
+//sinceLastFrame returns the time since a frame was counted, -1 when none was
+func (s *sourceStats) sinceLastFrame() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.frames == nil {
+		return -1
+	}
+
+	_, _, since := s.frames.read()
+	return since
+}
+
+//removeCounter removes the probe of c and returns the buffers it counted
+func removeCounter(c *padCounter) uint64 {
+	if c == nil {
+		return 0
+	}
+
+	buffers, _, _ := c.read()
+	c.remove()
+
+	return buffers
+}
+
+func (s *sourceStats) decodeError() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.decodeErrors++
+}
+
+func (s *sourceStats) reconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.reconnects++
+}
+
+func (s *sourceStats) snapshot() SourceStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := SourceStats{
+		Frames:          s.removedFrames,
+		Bytes:           s.removedBytes,
+		SinceLastBuffer: -1,
+		DecodeErrors:    s.decodeErrors,
+		Reconnects:      s.reconnects,
+	}
+
+	if s.frames != nil {
+		frames, _, since := s.frames.read()
+		stats.Frames += frames
+		stats.SinceLastBuffer = since
+
+		if width, err := strconv.Atoi(padCapsField(s.frames.pad, "width")); err == nil {
+			stats.Width = width
+		}
+		if height, err := strconv.Atoi(padCapsField(s.frames.pad, "height")); err == nil {
+			stats.Height = height
+		}
+	}
+
+	if s.encoded != nil {
+		_, bytes, _ := s.encoded.read()
+		stats.Bytes += bytes
+	}
+
+	stats.FPS, stats.Bitrate = s.rate.update(stats.Frames, stats.Bytes)
+
+	return stats
+}
+
+//String formats the stats for logs and event messages
+func (s SourceStats) String() string {
+	return fmt.Sprintf("%dx%d %.1ffps %.0fbps frames=%d since=%s errors=%d dropped=%d",
+		s.Width, s.Height, s.FPS, s.Bitrate, s.Frames, s.SinceLastBuffer, s.DecodeErrors, s.DroppedFrames)
+}
+
+//rateWindow turns buffer and byte totals into rates, the rates are
+//recomputed once per statsWindow and held in between
+type rateWindow struct {
+	sampled time.Time
+	buffers uint64
+	bytes   uint64
+	fps     float64
+	bitrate float64
+}
+
+func (r *rateWindow) update(buffers uint64, bytes uint64) (float64, float64) {
+	now := time.Now()
+	if r.sampled.IsZero() {
+		r.sampled = now
+		r.buffers = buffers
+		r.bytes = bytes
+	} else if elapsed := now.Sub(r.sampled); elapsed >= statsWindow {
+		r.fps = float64(buffers-r.buffers) / elapsed.Seconds()
+		r.bitrate = float64(bytes-r.bytes) * 8 / elapsed.Seconds()
+		r.sampled = now
+		r.buffers = buffers
+		r.bytes = bytes
+	}
+
+	return r.fps, r.bitrate
+}
+
+//RateStats are the counters of a RateCounter
+type RateStats struct {
+	Buffers uint64
+	Bytes   uint64
+	FPS     float64
+	//Bitrate in bits per second
+	Bitrate float64
+	//SinceLastBuffer is -1 while no buffer went through
+	SinceLastBuffer time.Duration
+}
+
+//RateCounter probes a pad outside of a source, like a compositor output
+type RateCounter struct {
+	counter *padCounter
+	rate    rateWindow
+
+	mutex sync.Mutex
+}
+
+//NewRateCounter starts counting the buffers going through pad
+func NewRateCounter(pad gstreamer.Pad) *RateCounter {
+	return &RateCounter{counter: newPadCounter(pad)}
+}
+
+//Stats returns the totals and rates counted so far
+func (r *RateCounter) Stats() RateStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := RateStats{SinceLastBuffer: -1}
+	if r.counter == nil {
+		return stats
+	}
+
+	stats.Buffers, stats.Bytes, stats.SinceLastBuffer = r.counter.read()
+	stats.FPS, stats.Bitrate = r.rate.update(stats.Buffers, stats.Bytes)
+
+	return stats
+}
+
+//Remove removes the probe, the counter reads zero afterwards
+func (r *RateCounter) Remove() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.counter != nil {
+		r.counter.remove()
+		r.counter = nil
+	}
+}
@@ -8,10 +8,21 @@ import (
 )
 
 type Video interface {
+	Name() string
+	OnEvent(handler EventHandler)
+
 	SetPos(x int, y int)
 	SetSize(width int, height int)
 	SetBorder(border VideoBorder, value int)
+	SetFit(mode FitMode)
+	SetAlpha(alpha float32)
 	SetPipeline(pipeline gstreamer.Pipeline) error
+	ClearPipeline() error
+	SetFallback(options FallbackOptions) error
+
+	Label() *Label
+	Clock() *Clock
+	Stats() SourceStats
 
 	LinkSinkPad(gstreamer.Pad) (gstreamer.GstPadLinkReturn, error)
 
@@ -21,9 +32,26 @@ type Video interface {
 type Videos []Video
 
 type video struct {
+	eventEmitter
+
+	id        int
 	videosrc  gstreamer.Element
 	videobox  gstreamer.Element
 	videosink gstreamer.Pad
+	label     *Label
+	clock     *Clock
+	framesPad gstreamer.Pad
+	fallback  *fallback
+	stats     sourceStats
+
+	width  int
+	height int
+
+	fit          FitMode
+	sourceWidth  int
+	sourceHeight int
+	borders      [4]int
+	fitBorders   [4]int
 
 	pipeline gstreamer.Pipeline
 }
@@ -48,19 +76,24 @@ var videoIDGenerator = 0
 
 //NewVideo returns a gstreamer video wrapper
 func NewVideo(width int, height int, factory string) (Video, error) {
-	video := &video{}
 	videosrc, err := gstreamer.NewElement(factory, fmt.Sprintf("source_%d", videoIDGenerator))
 	if err != nil {
 		return nil, err
 	}
 
-	videobox, err := gstreamer.NewElement("videobox", fmt.Sprintf("box_%d", videoIDGenerator))
+	return NewVideoFromElement(videosrc, width, height)
+}
+
+//NewVideoFromElement wraps an already created raw video element
+func NewVideoFromElement(videosrc gstreamer.Element, width int, height int) (Video, error) {
+	video := &video{}
+
+	err := video.initTail(videoIDGenerator)
 	if err != nil {
 		return nil, err
 	}
 
 	video.videosrc = videosrc
-	video.videobox = videobox
 
 	videoIDGenerator++
 	video.SetSize(width, height)
@@ -68,6 +101,166 @@ func NewVideo(width int, height int, factory string) (Video, error) {
 	return video, nil
 }
 
+//initTail creates the elements every source ends with: the optional no
+//signal fallback, the clock, the label and the box
+func (v *video) initTail(id int) error {
+	videobox, err := gstreamer.NewElement("videobox", fmt.Sprintf("box_%d", id))
+	if err != nil {
+		return err
+	}
+
+	label, err := newLabel(id)
+	if err != nil {
+		return err
+	}
+
+	clock, err := newSourceClock(id)
+	if err != nil {
+		return err
+	}
+
+	v.id = id
+	v.videobox = videobox
+	v.label = label
+	v.clock = clock
+
+	return nil
+}
+
+//addTail adds the tail elements to the pipeline and links them after from.
+//Decoded frames are counted on framesPad, or where they enter the tail for
+//sources decoding in the compositor pipeline
+func (v *video) addTail(pipeline gstreamer.Pipeline, from gstreamer.Element) error {
+	if !pipeline.Add(v.clock.overlay) ||
+		!pipeline.Add(v.label.overlay) ||
+		!pipeline.Add(v.videobox) {
+		return ErrVideoSetPipeline
+	}
+
+	pad := v.framesPad
+	if pad == nil {
+		srcpad, err := from.GetStaticPad("src")
+		if err != nil {
+			return err
+		}
+		pad = srcpad
+	}
+	v.stats.countFrames(pad)
+
+	last := from
+	if v.fallback != nil {
+		if !v.fallback.add(pipeline, from) {
+			return ErrVideoLinkingSetPipeline
+		}
+
+		last = v.fallback.mixer
+	}
+
+	if !last.Link(v.clock.overlay) ||
+		!v.clock.overlay.Link(v.label.overlay) ||
+		!v.label.overlay.Link(v.videobox) {
+		return ErrVideoLinkingSetPipeline
+	}
+
+	if v.fallback != nil {
+		v.fallback.watch(v.stats.sinceLastFrame)
+	}
+	v.clock.start()
+
+	return nil
+}
+
+//removeTail unlinks the tail from from and takes it out of the pipeline
+func (v *video) removeTail(pipeline gstreamer.Pipeline, from gstreamer.Element) bool {
+	v.clock.overlay.Unlink(v.label.overlay)
+	v.label.overlay.Unlink(v.videobox)
+	v.clock.stop()
+	v.stats.stopFrames()
+
+	if v.fallback != nil {
+		from.Unlink(v.fallback.mixer)
+		v.fallback.mixer.Unlink(v.clock.overlay)
+		if !v.fallback.remove(pipeline) {
+			return false
+		}
+	} else {
+		from.Unlink(v.clock.overlay)
+	}
+
+	if v.videosink != nil {
+		if srcpad, err := v.videobox.GetStaticPad("src"); err == nil {
+			srcpad.Unlink(v.videosink)
+		}
+		v.videosink = nil
+	}
+
+	return removeElement(pipeline, v.clock.overlay) &&
+		removeElement(pipeline, v.label.overlay) &&
+		removeElement(pipeline, v.videobox)
+}
+
+//removeElement stops e and takes it out of pipeline, removed elements keep
+//their state otherwise
+func removeElement(pipeline gstreamer.Pipeline, e gstreamer.Element) bool {
+	e.SetState(gstreamer.GstStateNull)
+	return pipeline.Remove(e)
+}
+
+//resizeTail keeps the tail elements that produce their own frames at the source size
+func (v *video) resizeTail(width int, height int) {
+	v.width = width
+	v.height = height
+
+	if v.fallback != nil {
+		v.fallback.setSize(width, height)
+	}
+}
+
+//SetFallback shows a placeholder picture whenever the source stops producing
+//frames, it must be set before the video is added to a compositor. The
+//placeholder is shown until the first frame and every switch is an event
+func (v *video) SetFallback(options FallbackOptions) error {
+	if v.pipeline != nil {
+		return ErrVideoFallbackLinked
+	}
+
+	fallback, err := newFallback(options, v.id)
+	if err != nil {
+		return err
+	}
+
+	if v.fallback != nil {
+		v.fallback.stop()
+	}
+
+	fallback.onChange = func(active bool) {
+		if active {
+			v.emit(v.Name(), EventPlaceholderShown, options.Location)
+		} else {
+			v.emit(v.Name(), EventPlaceholderHidden, options.Location)
+		}
+	}
+
+	v.fallback = fallback
+	if v.width > 0 && v.height > 0 {
+		fallback.setSize(v.width, v.height)
+	}
+
+	return nil
+}
+
+//setSignalLost shows the fallback placeholder, if any, until the signal is back
+func (v *video) setSignalLost(lost bool) {
+	if v.fallback != nil {
+		v.fallback.force(lost)
+	}
+}
+
+//Name returns the source element name
+func (v *video) Name() string {
+	return v.videosrc.GetName()
+}
+
 func (v *video) SetPos(x int, y int) {
 	if v.videosink != nil {
 		v.videosink.Set("xpos", x)
@@ -75,47 +268,96 @@ func (v *video) SetPos(x int, y int) {
 	}
 }
 
+func (v *video) SetAlpha(alpha float32) {
+	if v.videosink != nil {
+		v.videosink.Set("alpha", alpha)
+	}
+}
+
 func (v *video) SetSize(width int, height int) {
 	caps, _ := gstreamer.NewCapsFromString(fmt.Sprintf("%s,width=%d,height=%d", v.getCapsProps(), width, height))
 	v.videosrc.Set("caps", caps)
+	v.resizeTail(width, height)
 }
 
 func (v *video) SetBorder(border VideoBorder, value int) {
 	if border&VideoBorderTop != 0 {
-		v.videobox.Set("top", value)
+		v.borders[borderTop] = value
 	}
 
 	if border&VideoBorderRight != 0 {
-		v.videobox.Set("right", value)
+		v.borders[borderRight] = value
 	}
 
 	if border&VideoBorderBottom != 0 {
-		v.videobox.Set("bottom", value)
+		v.borders[borderBottom] = value
 	}
 
 	if border&VideoBorderLeft != 0 {
-		v.videobox.Set("left", value)
+		v.borders[borderLeft] = value
 	}
+
+	v.applyBorders()
 }
 
-func (v *video) SetPipeline(pipeline gstreamer.Pipeline) error {
-	if v.pipeline != nil {
-		v.videosrc.Unlink(v.videobox)
+//SetFit sets how the picture is scaled into its slot, sources that don't
+//know their picture size always fill it
+func (v *video) SetFit(mode FitMode) {
+	v.fit = mode
+}
 
-		if !v.pipeline.Remove(v.videosrc) || !v.pipeline.Remove(v.videobox) {
-			return ErrVideoSetPipeline
-		}
+//fitSize returns the size the picture is scaled to before the box brings it
+//back to width x height
+func (v *video) fitSize(width int, height int) (int, int) {
+	scaledWidth, scaledHeight, borders := fitPicture(v.fit, v.sourceWidth, v.sourceHeight, width, height)
+	v.fitBorders = borders
+	v.applyBorders()
+
+	return scaledWidth, scaledHeight
+}
+
+//applyBorders sets the box to the layout borders plus the fit borders
+func (v *video) applyBorders() {
+	v.videobox.Set("top", v.borders[borderTop]+v.fitBorders[borderTop])
+	v.videobox.Set("right", v.borders[borderRight]+v.fitBorders[borderRight])
+	v.videobox.Set("bottom", v.borders[borderBottom]+v.fitBorders[borderBottom])
+	v.videobox.Set("left", v.borders[borderLeft]+v.fitBorders[borderLeft])
+}
+
+func (v *video) SetPipeline(pipeline gstreamer.Pipeline) error {
+	if err := v.ClearPipeline(); err != nil {
+		return err
 	}
 
-	if !pipeline.Add(v.videobox) || !pipeline.Add(v.videosrc) || !v.videosrc.Link(v.videobox) {
+	if !pipeline.Add(v.videosrc) {
 		return ErrVideoSetPipeline
 	}
 
+	if err := v.addTail(pipeline, v.videosrc); err != nil {
+		return err
+	}
+
 	v.pipeline = pipeline
 
 	return nil
 }
 
+//ClearPipeline stops the source elements and takes them out of their
+//pipeline. The pipeline owned them, so the source can't be added again
+func (v *video) ClearPipeline() error {
+	if v.pipeline == nil {
+		return nil
+	}
+
+	if !v.removeTail(v.pipeline, v.videosrc) || !removeElement(v.pipeline, v.videosrc) {
+		return ErrVideoSetPipeline
+	}
+
+	v.pipeline = nil
+
+	return nil
+}
+
 func (v *video) LinkSinkPad(sink gstreamer.Pad) (gstreamer.GstPadLinkReturn, error) {
 	srcpad, err := v.videobox.GetStaticPad("src")
 	if err != nil {
@@ -131,6 +373,22 @@ func (v *video) LinkSinkPad(sink gstreamer.Pad) (gstreamer.GstPadLinkReturn, err
 	return result, nil
 }
 
+//Stats returns the frame rate, bitrate and error counters of the source
+func (v *video) Stats() SourceStats {
+	stats := v.stats.snapshot()
+	stats.Source = v.Name()
+
+	return stats
+}
+
+func (v *video) Label() *Label {
+	return v.label
+}
+
+func (v *video) Clock() *Clock {
+	return v.clock
+}
+
 func (v *video) Raw() gstreamer.Element {
 	return v.videosrc
 }
@@ -0,0 +1,162 @@
+package compositor
+
+import (
+	"fmt"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//stillImage decodes a picture once and repeats it as a video stream into a mixer pad
+type stillImage struct {
+	filesrc gstreamer.Element
+	decoder gstreamer.Element
+	convert gstreamer.Element
+	scale   gstreamer.Element
+	filter  gstreamer.Element
+	freeze  gstreamer.Element
+
+	width  int
+	height int
+}
+
+var imageIDGenerator = 0
+
+//newStillImage creates the decoding chain, a zero width or height keeps the picture size
+func newStillImage(location string, width int, height int) (*stillImage, error) {
+	imageWidth, imageHeight, err := element.ImageSize(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if width <= 0 || height <= 0 {
+		width = imageWidth
+		height = imageHeight
+	}
+
+	id := imageIDGenerator
+	imageIDGenerator++
+
+	filesrc, err := gstreamer.NewElement("filesrc", fmt.Sprintf("image_source_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	filesrc.Set("location", location)
+
+	decoder, err := gstreamer.NewElement("decodebin", fmt.Sprintf("image_decoder_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	convert, err := gstreamer.NewElement("videoconvert", fmt.Sprintf("image_convert_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	scale, err := gstreamer.NewElement("videoscale", fmt.Sprintf("image_scale_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := gstreamer.NewElement("capsfilter", fmt.Sprintf("image_filter_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	freeze, err := gstreamer.NewElement("imagefreeze", fmt.Sprintf("image_freeze_%d", id))
+	if err != nil {
+		return nil, err
+	}
+
+	img := &stillImage{
+		filesrc: filesrc,
+		decoder: decoder,
+		convert: convert,
+		scale:   scale,
+		filter:  filter,
+		freeze:  freeze,
+	}
+
+	if err := img.setSize(width, height); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+func (i *stillImage) setSize(width int, height int) error {
+	caps, err := gstreamer.NewCapsFromString(fmt.Sprintf("video/x-raw,format=AYUV,width=%d,height=%d", width, height))
+	if err != nil {
+		return err
+	}
+
+	i.filter.Set("caps", caps)
+	i.width = width
+	i.height = height
+
+	return nil
+}
+
+func (i *stillImage) elements() []gstreamer.Element {
+	return []gstreamer.Element{i.filesrc, i.decoder, i.convert, i.scale, i.filter, i.freeze}
+}
+
+//add puts the chain in the pipeline and links it into the mixer pad
+func (i *stillImage) add(pipeline gstreamer.Pipeline, sink gstreamer.Pad) error {
+	for _, e := range i.elements() {
+		if !pipeline.Add(e) {
+			return ErrCreateCompositor
+		}
+	}
+
+	i.decoder.SetOnPadAddedCallback(func(element gstreamer.Element, pad gstreamer.Pad) {
+		sinkpad, err := i.convert.GetStaticPad("sink")
+		if err != nil {
+			logging.Error(err)
+			return
+		}
+
+		if result := pad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+			logging.Error(fmt.Sprintf("Failed to link image decoder pad: %d", result))
+		}
+	})
+
+	if !i.filesrc.Link(i.decoder) ||
+		!i.convert.Link(i.scale) ||
+		!i.scale.Link(i.filter) ||
+		!i.filter.Link(i.freeze) {
+		return fmt.Errorf("Failed to link image elements")
+	}
+
+	srcpad, err := i.freeze.GetStaticPad("src")
+	if err != nil {
+		return err
+	}
+
+	if result := srcpad.Link(sink); result != gstreamer.GstPadLinkOk {
+		return fmt.Errorf("Failed to link image with mixer: %d", result)
+	}
+
+	return nil
+}
+
+//remove stops the chain and takes it out of the pipeline
+func (i *stillImage) remove(pipeline gstreamer.Pipeline, sink gstreamer.Pad) {
+	if srcpad, err := i.freeze.GetStaticPad("src"); err == nil {
+		srcpad.Unlink(sink)
+	}
+
+	for _, e := range i.elements() {
+		e.SetState(gstreamer.GstStateNull)
+		pipeline.Remove(e)
+	}
+}
+
+//setState moves the chain to the pipeline state, downstream elements first
+func (i *stillImage) setState(state gstreamer.GstState) {
+	elements := i.elements()
+	for n := len(elements) - 1; n >= 0; n-- {
+		elements[n].SetState(state)
+	}
+}
@@ -19,6 +19,8 @@ type LayoutSlot struct {
 	borderBottom int
 	borderLeft   int
 
+	fit element.FitMode
+
 	group string
 }
 
@@ -117,7 +119,13 @@ func (l *Layout) ApplyLayout(videos element.Videos) error {
 	return nil
 }
 
+//SetFit sets how sources are scaled into the slot
+func (l *LayoutSlot) SetFit(mode element.FitMode) {
+	l.fit = mode
+}
+
 func (l *LayoutSlot) applyLayout(video element.Video) {
+	video.SetFit(l.fit)
 	video.SetPos(l.posx, l.posy)
 	video.SetSize(l.sizex, l.sizey)
 	video.SetBorder(element.VideoBorderLeft, -l.borderLeft)
@@ -0,0 +1,304 @@
+package compositor
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/compositor/logging"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//OutputType ...
+type OutputType string
+
+//Output types
+const (
+	//OutputFake discards the canvas, to run a compositor only for its stats
+	OutputFake OutputType = "fake"
+	//OutputDisplay shows the canvas in a window
+	OutputDisplay OutputType = "display"
+	//OutputFile records the canvas as H.264 in a Matroska file
+	OutputFile OutputType = "file"
+	//OutputRTMP streams the canvas as H.264 in FLV to a RTMP server
+	OutputRTMP OutputType = "rtmp"
+)
+
+//OutputOptions configures an output chain
+type OutputOptions struct {
+	Type OutputType
+	//Location is the file path or the RTMP url
+	Location string
+	//Bitrate of encoded outputs in kbit/s, defaultOutputBitrate when zero
+	Bitrate int
+}
+
+//Output is a chain fed with the composed canvas
+type Output struct {
+	name     string
+	options  OutputOptions
+	elements []gstreamer.Element
+	teepad   gstreamer.Pad
+}
+
+//outputTee splits the canvas between the outputs
+type outputTee struct {
+	tee      gstreamer.Element
+	template gstreamer.PadTemplate
+}
+
+const defaultOutputBitrate = 2500
+
+//outputDrainTimeout bounds the wait for a removed output to get its EOS through
+const outputDrainTimeout = 5 * time.Second
+
+var (
+	ErrOutputType     = errors.New("Unknown output type")
+	ErrOutputLocation = errors.New("Output needs a location")
+)
+
+var outputIDGenerator int64
+
+func newOutputTee(id int) (*outputTee, error) {
+	tee, err := gstreamer.NewElement("tee", fmt.Sprintf("output_tee_%d", id))
+	if err != nil {
+		return nil, err
+	}
+	//the canvas keeps flowing while no output is linked
+	tee.Set("allow-not-linked", true)
+
+	template, err := tee.GetPadTemplate("src_%u")
+	if err != nil {
+		return nil, err
+	}
+
+	return &outputTee{tee: tee, template: template}, nil
+}
+
+func (t *outputTee) requestPad() (gstreamer.Pad, error) {
+	return t.tee.RequestPad(t.template, nil, nil)
+}
+
+//releasePad gives an unlinked pad back to the tee
+func (t *outputTee) releasePad(pad gstreamer.Pad) {
+	element.ReleaseRequestPad(t.tee, pad)
+}
+
+//Validate checks the options without creating the output
+func (o OutputOptions) Validate() error {
+	switch o.Type {
+	case OutputFake, OutputDisplay:
+	case OutputFile, OutputRTMP:
+		if o.Location == "" {
+			return ErrOutputLocation
+		}
+	default:
+		return ErrOutputType
+	}
+
+	return nil
+}
+
+//newOutputElements creates the output chain, the first element is fed by the tee
+//and the last one is the sink
+func newOutputElements(options OutputOptions, id int) ([]gstreamer.Element, error) {
+	factories := []string{"queue", "videoconvert"}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch options.Type {
+	case OutputFake:
+		factories = append(factories, "fakesink")
+	case OutputDisplay:
+		factories = append(factories, "autovideosink")
+	case OutputFile:
+		factories = append(factories, "x264enc", "h264parse", "matroskamux", "filesink")
+	case OutputRTMP:
+		factories = append(factories, "x264enc", "h264parse", "flvmux", "rtmpsink")
+	}
+
+	elements := make([]gstreamer.Element, len(factories))
+	for i, factory := range factories {
+		e, err := gstreamer.NewElement(factory, fmt.Sprintf("output_%s_%d", factory, id))
+		if err != nil {
+			return nil, err
+		}
+
+		switch factory {
+		case "queue":
+			e.Set("leaky", 2) //downstream, a slow output must not stall the others
+		case "x264enc":
+			bitrate := options.Bitrate
+			if bitrate <= 0 {
+				bitrate = defaultOutputBitrate
+			}
+			e.Set("bitrate", uint32(bitrate))
+			e.Set("speed-preset", 1) //ultrafast
+		case "flvmux":
+			e.Set("streamable", true)
+		case "filesink", "rtmpsink":
+			e.Set("location", options.Location)
+		case "fakesink", "autovideosink":
+			e.Set("sync", true)
+		}
+
+		elements[i] = e
+	}
+
+	return elements, nil
+}
+
+//NewOutput creates a named output chain without adding it, AttachOutput
+//feeds it with the canvas of a compositor
+func NewOutput(name string, options OutputOptions) (*Output, error) {
+	elements, err := newOutputElements(options, int(atomic.AddInt64(&outputIDGenerator, 1)-1))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Output{
+		name:     name,
+		options:  options,
+		elements: elements,
+	}, nil
+}
+
+//AddOutput adds a named output fed with the canvas, it can be called while
+//the compositor is running. The output sink is watched for OutputStats
+func (c *Compositor) AddOutput(name string, options OutputOptions) (*Output, error) {
+	output, err := NewOutput(name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.AttachOutput(output); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+//AttachOutput adds an output created with NewOutput, an output can only be
+//attached once
+func (c *Compositor) AttachOutput(output *Output) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	name := output.name
+	if _, ok := c.outputChains[name]; ok {
+		return ErrOutputExists
+	}
+
+	elements := output.elements
+	for _, e := range elements {
+		if !c.pipeline.Add(e) {
+			return ErrCreateCompositor
+		}
+	}
+
+	for i := 0; i < len(elements)-1; i++ {
+		if !elements[i].Link(elements[i+1]) {
+			return fmt.Errorf("Failed to link output %s", name)
+		}
+	}
+
+	teepad, err := c.outputTee.requestPad()
+	if err != nil {
+		return err
+	}
+
+	sinkpad, err := elements[0].GetStaticPad("sink")
+	if err != nil {
+		return err
+	}
+
+	if result := teepad.Link(sinkpad); result != gstreamer.GstPadLinkOk {
+		c.outputTee.releasePad(teepad)
+		return fmt.Errorf("Failed to link output %s: %d", name, result)
+	}
+	output.teepad = teepad
+
+	sink := elements[len(elements)-1]
+	counterpad, err := sink.GetStaticPad("sink")
+	if err != nil {
+		return err
+	}
+	c.outputs[name] = element.NewRateCounter(counterpad)
+	c.outputChains[name] = output
+
+	if c.state != gstreamer.GstStateNull {
+		for n := len(elements) - 1; n >= 0; n-- {
+			elements[n].SetState(c.state)
+		}
+	}
+
+	return nil
+}
+
+//RemoveOutput stops a named output and takes it out of the pipeline. While
+//the compositor plays, the output is cut from the tee and gets EOS first so
+//files are finalized, it waits up to outputDrainTimeout for it
+func (c *Compositor) RemoveOutput(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	output, ok := c.outputChains[name]
+	if !ok {
+		return ErrOutputNotFound
+	}
+
+	sinkpad, err := output.elements[0].GetStaticPad("sink")
+	if err != nil {
+		return err
+	}
+
+	if c.state == gstreamer.GstStatePlaying {
+		//the tee pad drops the canvas instead of holding it, the other outputs keep running
+		probe := element.DropPad(output.teepad)
+		defer probe.Remove()
+
+		last, err := output.elements[len(output.elements)-1].GetStaticPad("sink")
+		if err == nil && !element.DrainChain(sinkpad, last, outputDrainTimeout) {
+			logging.Error(fmt.Sprintf("Output %s was not drained", name))
+		}
+	}
+
+	output.teepad.Unlink(sinkpad)
+	c.outputTee.releasePad(output.teepad)
+
+	if counter, ok := c.outputs[name]; ok {
+		counter.Remove()
+		delete(c.outputs, name)
+	}
+	delete(c.outputChains, name)
+
+	for _, e := range output.elements {
+		e.SetState(gstreamer.GstStateNull)
+		c.pipeline.Remove(e)
+	}
+
+	return nil
+}
+
+//Output returns a named output added with AddOutput
+func (c *Compositor) Output(name string) (*Output, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	output, ok := c.outputChains[name]
+	return output, ok
+}
+
+//Name ...
+func (o *Output) Name() string {
+	return o.name
+}
+
+//Options returns the options the output was created with
+func (o *Output) Options() OutputOptions {
+	return o.options
+}
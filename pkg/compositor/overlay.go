@@ -0,0 +1,252 @@
+package compositor
+
+import (
+	"errors"
+	"sync"
+
+	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//Anchor is the canvas point an overlay position is relative to
+type Anchor int
+
+//Anchor points
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTopCenter
+	AnchorTopRight
+	AnchorCenterLeft
+	AnchorCenter
+	AnchorCenterRight
+	AnchorBottomLeft
+	AnchorBottomCenter
+	AnchorBottomRight
+)
+
+//overlayZOrderBase keeps canvas overlays above every source
+const overlayZOrderBase uint32 = 1000
+
+//ImageOverlayOptions ...
+type ImageOverlayOptions struct {
+	//Location is the picture path, PNG alpha is kept
+	Location string
+
+	//X and Y offset the overlay from its anchor, towards the canvas center
+	X      int
+	Y      int
+	Anchor Anchor
+
+	//Width and Height scale the picture, zero keeps its size
+	Width  int
+	Height int
+
+	//Opacity goes from 0 to 1, zero is opaque, SetOpacity(0) hides the overlay
+	Opacity float32
+	ZOrder  uint32
+}
+
+//ImageOverlay is a picture composited above all sources, like a logo or a badge
+type ImageOverlay struct {
+	name    string
+	options ImageOverlayOptions
+	image   *stillImage
+	sink    gstreamer.Pad
+	visible bool
+
+	mixer *Mixer
+	mutex sync.Mutex
+}
+
+var (
+	ErrOverlayExists   = errors.New("Overlay already exists")
+	ErrOverlayNotFound = errors.New("Overlay not found")
+)
+
+//NewImageOverlay decodes the picture of a named image overlay without adding
+//it, AttachImageOverlay draws it over the canvas of a compositor
+func NewImageOverlay(name string, options ImageOverlayOptions) (*ImageOverlay, error) {
+	if options.Opacity == 0 {
+		options.Opacity = 1
+	}
+
+	img, err := newStillImage(options.Location, options.Width, options.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImageOverlay{
+		name:    name,
+		options: options,
+		image:   img,
+		visible: true,
+	}, nil
+}
+
+//AddImageOverlay adds a named image overlay, it can be called while the compositor is running
+func (c *Compositor) AddImageOverlay(name string, options ImageOverlayOptions) (*ImageOverlay, error) {
+	overlay, err := NewImageOverlay(name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.AttachImageOverlay(overlay); err != nil {
+		return nil, err
+	}
+
+	return overlay, nil
+}
+
+//AttachImageOverlay adds an overlay created with NewImageOverlay, an overlay
+//can only be attached once
+func (c *Compositor) AttachImageOverlay(overlay *ImageOverlay) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.overlays[overlay.name]; ok {
+		return ErrOverlayExists
+	}
+
+	sink, err := c.mixer.requestPad()
+	if err != nil {
+		return err
+	}
+
+	img := overlay.image
+	err = img.add(c.pipeline, sink)
+	if err != nil {
+		img.remove(c.pipeline, sink)
+		c.mixer.releasePad(sink)
+		return err
+	}
+
+	overlay.mutex.Lock()
+	overlay.sink = sink
+	overlay.mixer = c.mixer
+	overlay.apply()
+	overlay.mutex.Unlock()
+
+	if c.state != gstreamer.GstStateNull {
+		img.setState(c.state)
+	}
+
+	c.overlays[overlay.name] = overlay
+
+	return nil
+}
+
+//RemoveImageOverlay removes a named image overlay, it can be called while the compositor is running
+func (c *Compositor) RemoveImageOverlay(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	overlay, ok := c.overlays[name]
+	if !ok {
+		return ErrOverlayNotFound
+	}
+
+	overlay.image.remove(c.pipeline, overlay.sink)
+	c.mixer.releasePad(overlay.sink)
+	delete(c.overlays, name)
+
+	return nil
+}
+
+//ImageOverlay returns a named image overlay
+func (c *Compositor) ImageOverlay(name string) (*ImageOverlay, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	overlay, ok := c.overlays[name]
+	return overlay, ok
+}
+
+//Name ...
+func (o *ImageOverlay) Name() string {
+	return o.name
+}
+
+//Options ...
+func (o *ImageOverlay) Options() ImageOverlayOptions {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.options
+}
+
+//SetVisible ...
+func (o *ImageOverlay) SetVisible(visible bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.visible = visible
+	o.apply()
+}
+
+//SetOpacity ...
+func (o *ImageOverlay) SetOpacity(opacity float32) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.options.Opacity = opacity
+	o.apply()
+}
+
+//SetPosition ...
+func (o *ImageOverlay) SetPosition(x int, y int, anchor Anchor) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.options.X = x
+	o.options.Y = y
+	o.options.Anchor = anchor
+	o.apply()
+}
+
+//SetZOrder orders overlays between themselves, they always stay above the sources
+func (o *ImageOverlay) SetZOrder(zorder uint32) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.options.ZOrder = zorder
+	o.apply()
+}
+
+func (o *ImageOverlay) apply() {
+	//the options of an overlay not attached yet are applied by AttachImageOverlay
+	if o.sink == nil {
+		return
+	}
+
+	x, y := o.options.Anchor.position(o.mixer.width, o.mixer.height, o.image.width, o.image.height, o.options.X, o.options.Y)
+
+	alpha := o.options.Opacity
+	if !o.visible {
+		alpha = 0
+	}
+
+	o.sink.Set("xpos", x)
+	o.sink.Set("ypos", y)
+	o.sink.Set("alpha", alpha)
+	o.sink.Set("zorder", overlayZOrderBase+o.options.ZOrder)
+}
+
+//position returns the top left corner of a width x height box anchored on the canvas
+func (a Anchor) position(canvasWidth int, canvasHeight int, width int, height int, x int, y int) (int, int) {
+	posx := x
+	switch a {
+	case AnchorTopCenter, AnchorCenter, AnchorBottomCenter:
+		posx = (canvasWidth-width)/2 + x
+	case AnchorTopRight, AnchorCenterRight, AnchorBottomRight:
+		posx = canvasWidth - width - x
+	}
+
+	posy := y
+	switch a {
+	case AnchorCenterLeft, AnchorCenter, AnchorCenterRight:
+		posy = (canvasHeight-height)/2 + y
+	case AnchorBottomLeft, AnchorBottomCenter, AnchorBottomRight:
+		posy = canvasHeight - height - y
+	}
+
+	return posx, posy
+}
@@ -0,0 +1,61 @@
+package compositor
+
+import (
+	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//Plugin is a GStreamer plugin and the element factories used from it
+type Plugin struct {
+	Name     string
+	Elements []string
+	//Optional plugins are only needed by some source types or outputs
+	Optional bool
+}
+
+//Plugins are the GStreamer plugins the compositor uses
+var Plugins = []Plugin{
+	{Name: "coreelements", Elements: []string{"capsfilter", "fakesink", "filesink", "filesrc", "queue", "tee"}},
+	{Name: "compositor", Elements: []string{"compositor"}},
+	{Name: "videoconvert", Elements: []string{"videoconvert"}},
+	{Name: "videoscale", Elements: []string{"videoscale"}},
+	{Name: "videobox", Elements: []string{"videobox"}},
+	{Name: "videotestsrc", Elements: []string{"videotestsrc"}},
+	{Name: "imagefreeze", Elements: []string{"imagefreeze"}},
+	{Name: "pango", Elements: []string{"textoverlay"}},
+	{Name: "inter", Elements: []string{"intervideosink", "intervideosrc", "interaudiosink", "interaudiosrc"}},
+	{Name: "playback", Elements: []string{"decodebin", "uridecodebin"}},
+	{Name: "app", Elements: []string{"appsrc", "appsink"}},
+	{Name: "audiomixer", Elements: []string{"audiomixer"}},
+	{Name: "audioconvert", Elements: []string{"audioconvert"}},
+	{Name: "audioresample", Elements: []string{"audioresample"}},
+	{Name: "udp", Elements: []string{"udpsrc"}, Optional: true},
+	{Name: "rtsp", Elements: []string{"rtspsrc"}, Optional: true},
+	{Name: "rtpmanager", Elements: []string{"rtpjitterbuffer", "rtpptdemux"}, Optional: true},
+	{Name: "rtp", Elements: []string{"rtpvp8depay", "rtpvp9depay", "rtph264depay"}, Optional: true},
+	//rtpav1depay ships with gst-plugins-rs, not gst-plugins-good
+	{Name: "rsrtp", Elements: []string{"rtpav1depay"}, Optional: true},
+	{Name: "vpx", Elements: []string{"vp8dec", "vp9dec"}, Optional: true},
+	{Name: "libav", Elements: []string{"avdec_h264"}, Optional: true},
+	//either AV1 decoder is enough, dav1ddec is preferred
+	{Name: "dav1d", Elements: []string{"dav1ddec"}, Optional: true},
+	{Name: "aom", Elements: []string{"av1dec"}, Optional: true},
+	{Name: "x264", Elements: []string{"x264enc"}, Optional: true},
+	{Name: "videoparsersbad", Elements: []string{"h264parse"}, Optional: true},
+	{Name: "matroska", Elements: []string{"matroskamux"}, Optional: true},
+	{Name: "flv", Elements: []string{"flvmux"}, Optional: true},
+	{Name: "rtmp", Elements: []string{"rtmpsink"}, Optional: true},
+	{Name: "autodetect", Elements: []string{"autovideosink"}, Optional: true},
+}
+
+//Missing returns the plugin elements that can't be found in the registry
+func (p Plugin) Missing() []string {
+	missing := make([]string, 0)
+
+	for _, name := range p.Elements {
+		if _, err := gstreamer.NewElementFactory(name); err != nil {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,319 @@
+package compositor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+//Overlay is anything drawn on top of the sources that a scene can show or hide
+type Overlay interface {
+	SetVisible(visible bool)
+}
+
+//Scene bundles a layout, the sources it shows and its overlays
+type Scene struct {
+	name     string
+	layout   *Layout
+	videos   element.Videos
+	overlays []Overlay
+}
+
+//TransitionType ...
+type TransitionType int
+
+//Transition types
+const (
+	TransitionCut TransitionType = iota
+	TransitionFade
+)
+
+//Transition describes how a scene switch is presented
+type Transition struct {
+	Type     TransitionType
+	Duration time.Duration
+}
+
+const transitionStep = 40 * time.Millisecond
+
+var (
+	ErrSceneExists   = errors.New("Scene already exists")
+	ErrSceneNotFound = errors.New("Scene not found")
+)
+
+//NewScene ...
+func NewScene(name string, layout *Layout) *Scene {
+	return &Scene{
+		name:   name,
+		layout: layout,
+	}
+}
+
+//Name ...
+func (s *Scene) Name() string {
+	return s.name
+}
+
+//Layout ...
+func (s *Scene) Layout() *Layout {
+	return s.layout
+}
+
+//AddVideo adds a visible source to the scene, sources fill the layout slots in insertion order
+func (s *Scene) AddVideo(v element.Video) {
+	s.videos = append(s.videos, v)
+}
+
+//AddOverlay ...
+func (s *Scene) AddOverlay(o Overlay) {
+	s.overlays = append(s.overlays, o)
+}
+
+//Videos ...
+func (s *Scene) Videos() element.Videos {
+	return s.videos
+}
+
+func (s *Scene) removeVideo(v element.Video) {
+	for i, video := range s.videos {
+		if video == v {
+			s.videos = append(s.videos[:i], s.videos[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Scene) replaceVideo(old element.Video, v element.Video) {
+	for i, video := range s.videos {
+		if video == old {
+			s.videos[i] = v
+			return
+		}
+	}
+}
+
+func (s *Scene) hasVideo(v element.Video) bool {
+	for _, video := range s.videos {
+		if video == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Scene) hasOverlay(o Overlay) bool {
+	for _, overlay := range s.overlays {
+		if overlay == o {
+			return true
+		}
+	}
+
+	return false
+}
+
+//apply shows the scene over the compositor sources at the given alpha, previous is the scene being replaced and may be nil
+func (s *Scene) apply(videos element.Videos, previous *Scene, alpha float32) error {
+	var visible element.Videos
+	for _, v := range videos {
+		if s.hasVideo(v) {
+			visible = append(visible, v)
+		} else {
+			v.SetAlpha(0)
+		}
+	}
+
+	if previous != nil {
+		for _, o := range previous.overlays {
+			if !s.hasOverlay(o) {
+				o.SetVisible(false)
+			}
+		}
+	}
+
+	if s.layout != nil && len(visible) > 0 {
+		if err := s.layout.ApplyLayout(visible); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range visible {
+		v.SetAlpha(alpha)
+	}
+
+	for _, o := range s.overlays {
+		o.SetVisible(true)
+	}
+
+	return nil
+}
+
+func (s *Scene) setAlpha(videos element.Videos, alpha float32) {
+	for _, v := range videos {
+		if s.hasVideo(v) {
+			v.SetAlpha(alpha)
+		}
+	}
+}
+
+//AddScene registers a scene, names must be unique
+func (c *Compositor) AddScene(s *Scene) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.scenes[s.name]; ok {
+		return ErrSceneExists
+	}
+
+	c.scenes[s.name] = s
+
+	return nil
+}
+
+//RemoveScene unregisters a scene, the active scene can't be removed
+func (c *Compositor) RemoveScene(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s, ok := c.scenes[name]
+	if !ok {
+		return ErrSceneNotFound
+	}
+
+	if s == c.scene {
+		return fmt.Errorf("Scene %s is active", name)
+	}
+
+	delete(c.scenes, name)
+
+	return nil
+}
+
+//Scene returns a registered scene by name
+func (c *Compositor) Scene(name string) (*Scene, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s, ok := c.scenes[name]
+	return s, ok
+}
+
+//CurrentScene returns the active scene name, empty when no scene is active
+func (c *Compositor) CurrentScene() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.scene == nil {
+		return ""
+	}
+
+	return c.scene.name
+}
+
+//SwitchScene cuts to the named scene
+func (c *Compositor) SwitchScene(name string) error {
+	return c.SwitchSceneWithTransition(name, Transition{Type: TransitionCut})
+}
+
+//SwitchSceneWithTransition switches to the named scene, fades dip the outgoing scene
+//out and the incoming one in and block until the transition is done. The
+//compositor isn't locked while fading, a newer switch cancels the fade and
+//takes over from the alphas it left
+func (c *Compositor) SwitchSceneWithTransition(name string, t Transition) error {
+	c.mutex.Lock()
+
+	s, ok := c.scenes[name]
+	if !ok {
+		c.mutex.Unlock()
+		return ErrSceneNotFound
+	}
+
+	if c.transition != nil {
+		close(c.transition)
+		c.transition = nil
+	}
+
+	previous := c.scene
+	if t.Type != TransitionFade || t.Duration <= 0 {
+		defer c.mutex.Unlock()
+
+		if err := s.apply(c.videos, previous, 1); err != nil {
+			return err
+		}
+		c.scene = s
+
+		return nil
+	}
+
+	cancel := make(chan struct{})
+	c.transition = cancel
+	c.mutex.Unlock()
+
+	if previous != nil && !c.fade(cancel, previous, 1, 0, t.Duration/2) {
+		return nil
+	}
+
+	c.mutex.Lock()
+	if cancelled(cancel) {
+		c.mutex.Unlock()
+		return nil
+	}
+
+	if err := s.apply(c.videos, previous, 0); err != nil {
+		c.transition = nil
+		c.mutex.Unlock()
+		return err
+	}
+	c.scene = s
+	c.mutex.Unlock()
+
+	if !c.fade(cancel, s, 0, 1, t.Duration/2) {
+		return nil
+	}
+
+	c.mutex.Lock()
+	if c.transition == cancel {
+		c.transition = nil
+	}
+	c.mutex.Unlock()
+
+	return nil
+}
+
+//fade ramps the alpha of a scene sources, the compositor is only locked to
+//set each step. It returns false when a newer switch cancelled it
+func (c *Compositor) fade(cancel chan struct{}, s *Scene, from float32, to float32, duration time.Duration) bool {
+	steps := int(duration / transitionStep)
+	if steps < 1 {
+		steps = 1
+	}
+
+	for i := 1; i <= steps; i++ {
+		c.mutex.Lock()
+		if cancelled(cancel) {
+			c.mutex.Unlock()
+			return false
+		}
+		s.setAlpha(c.videos, from+(to-from)*float32(i)/float32(steps))
+		c.mutex.Unlock()
+
+		select {
+		case <-cancel:
+			return false
+		case <-time.After(duration / time.Duration(steps)):
+		}
+	}
+
+	return true
+}
+
+func cancelled(cancel chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,480 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//Instance is a compositor built from a configuration, Apply moves it to
+//another configuration while it runs
+type Instance struct {
+	Compositor *compositor.Compositor
+
+	config   *Config
+	sources  map[string]*source
+	overlays map[string]Overlay
+	outputs  map[string]Output
+
+	watchDone chan struct{}
+	mutex     sync.Mutex
+}
+
+type source struct {
+	config Source
+	video  element.Video
+}
+
+var ErrRestart = errors.New("Name and canvas size changes need a restart")
+
+//Build creates the compositor of a configuration with its sources, layout,
+//overlays and outputs, it's left stopped
+func Build(c *Config) (*Instance, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	width, height := c.Canvas.Size()
+	cmp, err := compositor.NewCompositorWithCanvas(width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &Instance{
+		Compositor: cmp,
+		config:     &Config{Name: c.Name, Canvas: Canvas{Width: c.Canvas.Width, Height: c.Canvas.Height}},
+		sources:    make(map[string]*source),
+		overlays:   make(map[string]Overlay),
+		outputs:    make(map[string]Output),
+	}
+
+	if err := instance.Apply(c); err != nil {
+		cmp.Stop()
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+//Apply diffs a configuration against the applied one and only adds, removes
+//or updates what changed. A changed source or output is replaced, a replaced
+//source keeps its place in the layout, and an overlay that only moved is
+//updated in place. A removed layout leaves the last one in place.
+//
+//Everything new is built before anything is swapped in, so invalid
+//configurations and sources, pictures or outputs that can't be created are
+//rejected with nothing changed. Name and canvas size changes are rejected
+//with ErrRestart
+func (i *Instance) Apply(next *Config) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	width, height := next.Canvas.Size()
+	currentWidth, currentHeight := i.config.Canvas.Size()
+	if next.Name != i.config.Name || width != currentWidth || height != currentHeight {
+		return ErrRestart
+	}
+
+	created := make(map[string]element.Video)
+	for _, s := range next.Sources {
+		if current, ok := i.sources[s.ID]; ok && reflect.DeepEqual(current.config, s) {
+			continue
+		}
+
+		video, err := newSource(s)
+		if err != nil {
+			discardSources(created)
+			return fmt.Errorf("sources %s: %w", s.ID, err)
+		}
+		created[s.ID] = video
+	}
+
+	changes, err := i.prepare(next)
+	if err != nil {
+		discardSources(created)
+		return err
+	}
+	changes.sources = created
+
+	if err := i.swap(next, changes); err != nil {
+		i.config = i.applied(next)
+		return err
+	}
+
+	i.config = next
+
+	return nil
+}
+
+//changes holds everything Apply built for the next configuration
+type changes struct {
+	sources  map[string]element.Video
+	layout   *compositor.Layout
+	overlays map[string]*compositor.ImageOverlay
+	outputs  map[string]*compositor.Output
+
+	background        *compositor.Background
+	backgroundChanged bool
+}
+
+//prepare builds the layout, background, overlays and outputs of next that
+//differ from the applied ones, without adding them
+func (i *Instance) prepare(next *Config) (*changes, error) {
+	c := &changes{
+		overlays: make(map[string]*compositor.ImageOverlay),
+		outputs:  make(map[string]*compositor.Output),
+	}
+
+	if next.Layout != nil && !reflect.DeepEqual(i.config.Layout, next.Layout) {
+		layout, err := NewLayout(*next.Layout)
+		if err != nil {
+			return nil, err
+		}
+		c.layout = layout
+	}
+
+	if !reflect.DeepEqual(i.config.Canvas.Background, next.Canvas.Background) {
+		c.backgroundChanged = true
+
+		if next.Canvas.Background != nil {
+			background, err := i.newBackground(next.Canvas.Background)
+			if err != nil {
+				return nil, err
+			}
+			c.background = background
+		}
+	}
+
+	for _, o := range next.Overlays {
+		if current, ok := i.overlays[o.ID]; ok && samePicture(current, o) {
+			continue
+		}
+
+		options := o.options()
+		overlay, err := compositor.NewImageOverlay(o.ID, options)
+		if err != nil {
+			return nil, fmt.Errorf("overlays %s: %w", o.ID, err)
+		}
+		//a zero option is opaque, an explicit opacity of 0 is kept
+		overlay.SetOpacity(options.Opacity)
+		c.overlays[o.ID] = overlay
+	}
+
+	for _, o := range next.Outputs {
+		if current, ok := i.outputs[o.ID]; ok && current == o {
+			continue
+		}
+
+		output, err := compositor.NewOutput(o.ID, o.options())
+		if err != nil {
+			return nil, fmt.Errorf("outputs %s: %w", o.ID, err)
+		}
+		c.outputs[o.ID] = output
+	}
+
+	return c, nil
+}
+
+func (i *Instance) newBackground(background *Background) (*compositor.Background, error) {
+	switch {
+	case background.Color != "":
+		color, err := parseColor(background.Color)
+		if err != nil {
+			return nil, err
+		}
+		return i.Compositor.NewBackgroundColor(color)
+	case background.Image != "":
+		return i.Compositor.NewBackgroundImage(background.Image)
+	default:
+		return i.Compositor.NewBackgroundVideo(background.Video)
+	}
+}
+
+//samePicture tells if an overlay can be kept and only moved
+func samePicture(current Overlay, next Overlay) bool {
+	return next.Location == current.Location && next.Width == current.Width && next.Height == current.Height
+}
+
+func newSource(s Source) (element.Video, error) {
+	width, height := s.Width, s.Height
+	if width <= 0 {
+		width = DefaultSourceWidth
+	}
+	if height <= 0 {
+		height = DefaultSourceHeight
+	}
+
+	return element.NewSource(s.Type, width, height, s.Config)
+}
+
+//discardSources stops sources that were created but never added
+func discardSources(videos map[string]element.Video) {
+	for _, video := range videos {
+		if s, ok := video.(element.StatefulVideo); ok {
+			s.SetState(gstreamer.GstStateNull)
+		}
+	}
+}
+
+//swap takes out what next doesn't have any more and puts the prepared
+//changes in, the instance keeps track of every step that succeeded
+func (i *Instance) swap(next *Config, c *changes) error {
+	if err := i.swapSources(next, c.sources); err != nil {
+		return err
+	}
+
+	if c.layout != nil {
+		i.Compositor.SetLayout(c.layout)
+		i.config.Layout = next.Layout
+	}
+
+	if c.backgroundChanged {
+		if c.background == nil {
+			i.Compositor.ClearBackground()
+		} else if err := i.Compositor.SetBackground(c.background); err != nil {
+			return err
+		}
+		i.config.Canvas.Background = next.Canvas.Background
+	}
+
+	if err := i.swapOverlays(next, c.overlays); err != nil {
+		return err
+	}
+
+	return i.swapOutputs(next, c.outputs)
+}
+
+//swapSources removes the sources gone from next, replaces the changed ones in
+//their place and adds the new ones in the next order
+func (i *Instance) swapSources(next *Config, created map[string]element.Video) error {
+	wanted := make(map[string]bool)
+	for _, s := range next.Sources {
+		wanted[s.ID] = true
+	}
+
+	for _, id := range sortedKeys(i.sources) {
+		if wanted[id] {
+			continue
+		}
+
+		if err := i.Compositor.RemoveVideo(i.sources[id].video); err != nil {
+			return fmt.Errorf("sources %s: %w", id, err)
+		}
+		delete(i.sources, id)
+	}
+
+	for _, s := range next.Sources {
+		video, ok := created[s.ID]
+		if !ok {
+			continue
+		}
+		delete(created, s.ID)
+
+		var err error
+		if current, replaced := i.sources[s.ID]; replaced {
+			err = i.Compositor.ReplaceVideo(current.video, video)
+			delete(i.sources, s.ID)
+		} else {
+			err = i.Compositor.AddVideo(video)
+		}
+
+		if err != nil {
+			discardSources(created)
+			return fmt.Errorf("sources %s: %w", s.ID, err)
+		}
+		i.sources[s.ID] = &source{config: s, video: video}
+	}
+
+	return nil
+}
+
+//swapOverlays removes the overlays gone from next or with another picture,
+//adds the prepared ones and moves the kept ones
+func (i *Instance) swapOverlays(next *Config, prepared map[string]*compositor.ImageOverlay) error {
+	wanted := make(map[string]bool)
+	for _, o := range next.Overlays {
+		wanted[o.ID] = true
+	}
+
+	for _, id := range sortedKeys(i.overlays) {
+		if _, rebuilt := prepared[id]; wanted[id] && !rebuilt {
+			continue
+		}
+
+		if err := i.Compositor.RemoveImageOverlay(id); err != nil {
+			return fmt.Errorf("overlays %s: %w", id, err)
+		}
+		delete(i.overlays, id)
+	}
+
+	for _, o := range next.Overlays {
+		if overlay, ok := prepared[o.ID]; ok {
+			if err := i.Compositor.AttachImageOverlay(overlay); err != nil {
+				return fmt.Errorf("overlays %s: %w", o.ID, err)
+			}
+		} else if current := i.overlays[o.ID]; !reflect.DeepEqual(current, o) {
+			//only the placement changed, the picture is kept
+			overlay, found := i.Compositor.ImageOverlay(o.ID)
+			if !found {
+				return fmt.Errorf("overlays %s: %w", o.ID, compositor.ErrOverlayNotFound)
+			}
+
+			options := o.options()
+			overlay.SetPosition(options.X, options.Y, options.Anchor)
+			overlay.SetOpacity(options.Opacity)
+			overlay.SetZOrder(options.ZOrder)
+		}
+		i.overlays[o.ID] = o
+	}
+
+	return nil
+}
+
+//swapOutputs removes the outputs gone from next or changed and adds the prepared ones
+func (i *Instance) swapOutputs(next *Config, prepared map[string]*compositor.Output) error {
+	wanted := make(map[string]bool)
+	for _, o := range next.Outputs {
+		wanted[o.ID] = true
+	}
+
+	for _, id := range sortedKeys(i.outputs) {
+		if _, rebuilt := prepared[id]; wanted[id] && !rebuilt {
+			continue
+		}
+
+		if err := i.Compositor.RemoveOutput(id); err != nil {
+			return fmt.Errorf("outputs %s: %w", id, err)
+		}
+		delete(i.outputs, id)
+	}
+
+	for _, o := range next.Outputs {
+		output, ok := prepared[o.ID]
+		if !ok {
+			continue
+		}
+
+		if err := i.Compositor.AttachOutput(output); err != nil {
+			return fmt.Errorf("outputs %s: %w", o.ID, err)
+		}
+		i.outputs[o.ID] = o
+	}
+
+	return nil
+}
+
+//applied returns the configuration running after a swap failed half way,
+//made of what the instance tracks, in the next order then the left overs
+func (i *Instance) applied(next *Config) *Config {
+	c := *i.config
+	c.Sources = nil
+	c.Overlays = nil
+	c.Outputs = nil
+
+	for _, id := range appliedOrder(sourceIDs(next.Sources), i.sources) {
+		c.Sources = append(c.Sources, i.sources[id].config)
+	}
+	for _, id := range appliedOrder(overlayIDs(next.Overlays), i.overlays) {
+		c.Overlays = append(c.Overlays, i.overlays[id])
+	}
+	for _, id := range appliedOrder(outputIDs(next.Outputs), i.outputs) {
+		c.Outputs = append(c.Outputs, i.outputs[id])
+	}
+
+	return &c
+}
+
+//appliedOrder returns the keys of applied, those in ids first and in their order
+func appliedOrder(ids []string, applied interface{}) []string {
+	m := reflect.ValueOf(applied)
+	seen := make(map[string]bool)
+
+	var order []string
+	for _, id := range append(ids, sortedKeys(applied)...) {
+		if seen[id] || !m.MapIndex(reflect.ValueOf(id)).IsValid() {
+			continue
+		}
+		seen[id] = true
+		order = append(order, id)
+	}
+
+	return order
+}
+
+func sourceIDs(sources []Source) []string {
+	ids := make([]string, len(sources))
+	for n, s := range sources {
+		ids[n] = s.ID
+	}
+
+	return ids
+}
+
+func overlayIDs(overlays []Overlay) []string {
+	ids := make([]string, len(overlays))
+	for n, o := range overlays {
+		ids[n] = o.ID
+	}
+
+	return ids
+}
+
+func outputIDs(outputs []Output) []string {
+	ids := make([]string, len(outputs))
+	for n, o := range outputs {
+		ids[n] = o.ID
+	}
+
+	return ids
+}
+
+//sortedKeys returns the keys of a map with string keys in order, so changes
+//are applied in the same order every time
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+//Name returns the configuration name
+func (i *Instance) Name() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.config.Name
+}
+
+//Config returns the last configuration applied
+func (i *Instance) Config() *Config {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.config
+}
+
+//Source returns a source by its configuration id
+func (i *Instance) Source(id string) (element.Video, bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	s, ok := i.sources[id]
+	if !ok {
+		return nil, false
+	}
+
+	return s.video, true
+}
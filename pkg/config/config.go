@@ -0,0 +1,464 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"gopkg.in/yaml.v2"
+)
+
+//Config describes a whole production: the canvas, sources, layout, overlays
+//and outputs
+type Config struct {
+	Name     string    `json:"name" yaml:"name"`
+	Canvas   Canvas    `json:"canvas,omitempty" yaml:"canvas,omitempty"`
+	Sources  []Source  `json:"sources" yaml:"sources"`
+	Layout   *Layout   `json:"layout,omitempty" yaml:"layout,omitempty"`
+	Overlays []Overlay `json:"overlays,omitempty" yaml:"overlays,omitempty"`
+	Outputs  []Output  `json:"outputs" yaml:"outputs"`
+}
+
+//Canvas is the composed picture, zero sizes are the compositor defaults
+type Canvas struct {
+	Width      int         `json:"width,omitempty" yaml:"width,omitempty"`
+	Height     int         `json:"height,omitempty" yaml:"height,omitempty"`
+	Background *Background `json:"background,omitempty" yaml:"background,omitempty"`
+}
+
+//Background fills the canvas behind the sources, only one of its fields can
+//be set. Color is hexadecimal RRGGBB or AARRGGBB
+type Background struct {
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	Video string `json:"video,omitempty" yaml:"video,omitempty"`
+}
+
+//Source is a source from the source registry
+type Source struct {
+	ID     string                 `json:"id" yaml:"id"`
+	Type   string                 `json:"type" yaml:"type"`
+	Width  int                    `json:"width,omitempty" yaml:"width,omitempty"`
+	Height int                    `json:"height,omitempty" yaml:"height,omitempty"`
+	Config map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+}
+
+//Overlay is a picture above the sources, Opacity defaults to 1
+type Overlay struct {
+	ID       string   `json:"id" yaml:"id"`
+	Location string   `json:"location" yaml:"location"`
+	X        int      `json:"x,omitempty" yaml:"x,omitempty"`
+	Y        int      `json:"y,omitempty" yaml:"y,omitempty"`
+	Anchor   string   `json:"anchor,omitempty" yaml:"anchor,omitempty"`
+	Width    int      `json:"width,omitempty" yaml:"width,omitempty"`
+	Height   int      `json:"height,omitempty" yaml:"height,omitempty"`
+	Opacity  *float32 `json:"opacity,omitempty" yaml:"opacity,omitempty"`
+	ZOrder   uint32   `json:"zorder,omitempty" yaml:"zorder,omitempty"`
+}
+
+//Output is a compositor output, Bitrate is in kbit/s
+type Output struct {
+	ID       string `json:"id" yaml:"id"`
+	Type     string `json:"type" yaml:"type"`
+	Location string `json:"location,omitempty" yaml:"location,omitempty"`
+	Bitrate  int    `json:"bitrate,omitempty" yaml:"bitrate,omitempty"`
+}
+
+//Layout places the sources by how many of them are shown
+type Layout struct {
+	Width  int    `json:"width" yaml:"width"`
+	Height int    `json:"height" yaml:"height"`
+	Rules  []Rule `json:"rules" yaml:"rules"`
+}
+
+//Rule holds the slots used when Sources sources are shown
+type Rule struct {
+	Sources int    `json:"sources" yaml:"sources"`
+	Slots   []Slot `json:"slots" yaml:"slots"`
+}
+
+//Slot places one source, Borders are top, right, bottom and left
+type Slot struct {
+	X       int    `json:"x" yaml:"x"`
+	Y       int    `json:"y" yaml:"y"`
+	Width   int    `json:"width" yaml:"width"`
+	Height  int    `json:"height" yaml:"height"`
+	Borders [4]int `json:"borders,omitempty" yaml:"borders,omitempty"`
+	Fit     string `json:"fit,omitempty" yaml:"fit,omitempty"`
+}
+
+//Default source size, used when a source doesn't set one
+const (
+	DefaultSourceWidth  = 640
+	DefaultSourceHeight = 360
+)
+
+var (
+	ErrID         = errors.New("An id is required")
+	ErrDuplicate  = errors.New("Duplicated id")
+	ErrFit        = errors.New("Unknown fit mode")
+	ErrLayout     = errors.New("Every rule needs as many slots as sources")
+	ErrLayoutSize = errors.New("Layout needs a size")
+	ErrRule       = errors.New("Duplicated rule")
+	ErrNoRule     = errors.New("Layout has no rule for the sources")
+	ErrSlot       = errors.New("Slot is outside the canvas")
+	ErrCanvas     = errors.New("Canvas size must be positive")
+	ErrBackground = errors.New("Background needs exactly one of color, image or video")
+	ErrColor      = errors.New("Colors are hexadecimal RRGGBB or AARRGGBB")
+	ErrAnchor     = errors.New("Unknown anchor")
+	ErrLocation   = errors.New("Overlay needs a location")
+)
+
+var fitModes = map[string]element.FitMode{
+	"":        element.FitFill,
+	"fill":    element.FitFill,
+	"contain": element.FitContain,
+	"cover":   element.FitCover,
+}
+
+var anchors = map[string]compositor.Anchor{
+	"":              compositor.AnchorTopLeft,
+	"top-left":      compositor.AnchorTopLeft,
+	"top-center":    compositor.AnchorTopCenter,
+	"top-right":     compositor.AnchorTopRight,
+	"center-left":   compositor.AnchorCenterLeft,
+	"center":        compositor.AnchorCenter,
+	"center-right":  compositor.AnchorCenterRight,
+	"bottom-left":   compositor.AnchorBottomLeft,
+	"bottom-center": compositor.AnchorBottomCenter,
+	"bottom-right":  compositor.AnchorBottomRight,
+}
+
+//Load reads and validates a configuration file, .yaml and .yml files are
+//YAML and any other file is JSON. The name defaults to the file name
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFile(path, data)
+}
+
+func parseFile(path string, data []byte) (*Config, error) {
+	var c *Config
+	var err error
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		c, err = ParseYAML(data)
+	default:
+		c, err = Parse(data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if c.Name == "" {
+		c.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return c, nil
+}
+
+//Parse decodes and validates a JSON configuration, unknown fields are errors
+func Parse(data []byte) (*Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var c Config
+	if err := decoder.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+//ParseYAML decodes and validates a YAML configuration, unknown fields are errors
+func ParseYAML(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+//Validate checks the configuration without creating any element
+func (c *Config) Validate() error {
+	if err := c.Canvas.Validate(); err != nil {
+		return fmt.Errorf("canvas: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for i, s := range c.Sources {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("sources[%d]: %w", i, err)
+		}
+
+		if ids[s.ID] {
+			return fmt.Errorf("sources[%d]: %w: %s", i, ErrDuplicate, s.ID)
+		}
+		ids[s.ID] = true
+	}
+
+	ids = make(map[string]bool)
+	for i, o := range c.Overlays {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("overlays[%d]: %w", i, err)
+		}
+
+		if ids[o.ID] {
+			return fmt.Errorf("overlays[%d]: %w: %s", i, ErrDuplicate, o.ID)
+		}
+		ids[o.ID] = true
+	}
+
+	ids = make(map[string]bool)
+	for i, o := range c.Outputs {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+
+		if ids[o.ID] {
+			return fmt.Errorf("outputs[%d]: %w: %s", i, ErrDuplicate, o.ID)
+		}
+		ids[o.ID] = true
+	}
+
+	if c.Layout != nil {
+		if err := c.Layout.Validate(); err != nil {
+			return fmt.Errorf("layout: %w", err)
+		}
+
+		if len(c.Sources) > 0 && !c.Layout.HasRule(len(c.Sources)) {
+			return fmt.Errorf("layout: %w: %d", ErrNoRule, len(c.Sources))
+		}
+	}
+
+	return nil
+}
+
+//Validate checks the canvas size and background
+func (c Canvas) Validate() error {
+	if width, height := c.Size(); width <= 0 || height <= 0 {
+		return ErrCanvas
+	}
+
+	if c.Background == nil {
+		return nil
+	}
+
+	set := 0
+	for _, value := range []string{c.Background.Color, c.Background.Image, c.Background.Video} {
+		if value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return ErrBackground
+	}
+
+	if c.Background.Color != "" {
+		if _, err := parseColor(c.Background.Color); err != nil {
+			return err
+		}
+	}
+
+	if c.Background.Image != "" {
+		if _, err := os.Stat(c.Background.Image); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Size returns the canvas size, the compositor default one when unset
+func (c Canvas) Size() (int, int) {
+	if c.Width == 0 && c.Height == 0 {
+		return compositor.DefaultCanvasWidth, compositor.DefaultCanvasHeight
+	}
+
+	return c.Width, c.Height
+}
+
+//parseColor reads a RRGGBB or AARRGGBB colour, with an optional # or 0x
+//prefix, as ARGB. RRGGBB colours are opaque
+func parseColor(color string) (uint32, error) {
+	hex := strings.TrimPrefix(strings.TrimPrefix(color, "#"), "0x")
+	if len(hex) != 6 && len(hex) != 8 {
+		return 0, fmt.Errorf("%w: %s", ErrColor, color)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrColor, color)
+	}
+
+	if len(hex) == 6 {
+		value |= 0xff000000
+	}
+
+	return uint32(value), nil
+}
+
+//Validate checks the overlay picture exists and its anchor
+func (o Overlay) Validate() error {
+	if o.ID == "" {
+		return ErrID
+	}
+
+	if o.Location == "" {
+		return ErrLocation
+	}
+
+	if _, err := os.Stat(o.Location); err != nil {
+		return err
+	}
+
+	if _, ok := anchors[o.Anchor]; !ok {
+		return fmt.Errorf("%w: %s", ErrAnchor, o.Anchor)
+	}
+
+	return nil
+}
+
+func (o Overlay) options() compositor.ImageOverlayOptions {
+	opacity := float32(1)
+	if o.Opacity != nil {
+		opacity = *o.Opacity
+	}
+
+	return compositor.ImageOverlayOptions{
+		Location: o.Location,
+		X:        o.X,
+		Y:        o.Y,
+		Anchor:   anchors[o.Anchor],
+		Width:    o.Width,
+		Height:   o.Height,
+		Opacity:  opacity,
+		ZOrder:   o.ZOrder,
+	}
+}
+
+//Validate checks the source type and its parameters
+func (s Source) Validate() error {
+	if s.ID == "" {
+		return ErrID
+	}
+
+	sourceType, ok := element.LookupSource(s.Type)
+	if !ok {
+		return fmt.Errorf("%w: %s", element.ErrSourceTypeUnknown, s.Type)
+	}
+
+	_, err := sourceType.Validate(s.Config)
+	return err
+}
+
+//Validate checks the output type and location
+func (o Output) Validate() error {
+	if o.ID == "" {
+		return ErrID
+	}
+
+	return o.options().Validate()
+}
+
+func (o Output) options() compositor.OutputOptions {
+	return compositor.OutputOptions{
+		Type:     compositor.OutputType(o.Type),
+		Location: o.Location,
+		Bitrate:  o.Bitrate,
+	}
+}
+
+//Validate checks every rule has a slot per source, inside the canvas
+func (l Layout) Validate() error {
+	if l.Width <= 0 || l.Height <= 0 {
+		return ErrLayoutSize
+	}
+
+	rules := make(map[int]bool)
+	for i, r := range l.Rules {
+		if r.Sources <= 0 || len(r.Slots) != r.Sources {
+			return fmt.Errorf("rules[%d]: %w", i, ErrLayout)
+		}
+
+		if rules[r.Sources] {
+			return fmt.Errorf("rules[%d]: %w for %d sources", i, ErrRule, r.Sources)
+		}
+		rules[r.Sources] = true
+
+		for j, s := range r.Slots {
+			if _, ok := fitModes[s.Fit]; !ok {
+				return fmt.Errorf("rules[%d].slots[%d]: %w: %s", i, j, ErrFit, s.Fit)
+			}
+
+			if s.Width <= 0 || s.Height <= 0 || s.X < 0 || s.Y < 0 ||
+				s.X+s.Width > l.Width || s.Y+s.Height > l.Height {
+				return fmt.Errorf("rules[%d].slots[%d]: %w", i, j, ErrSlot)
+			}
+		}
+	}
+
+	return nil
+}
+
+//HasRule tells if the layout can place that many sources
+func (l Layout) HasRule(sources int) bool {
+	for _, r := range l.Rules {
+		if r.Sources == sources {
+			return true
+		}
+	}
+
+	return false
+}
+
+//NewLayout builds a compositor layout, rules must fill every slot as
+//Layout.ApplyLayout indexes slots by source
+func NewLayout(l Layout) (*compositor.Layout, error) {
+	layout := compositor.NewLayout(l.Width, l.Height)
+
+	for _, r := range l.Rules {
+		if r.Sources <= 0 || len(r.Slots) != r.Sources {
+			return nil, ErrLayout
+		}
+
+		rule := compositor.NewLayoutRule()
+		for _, s := range r.Slots {
+			fit, ok := fitModes[s.Fit]
+			if !ok {
+				return nil, ErrFit
+			}
+
+			slot := compositor.NewLayoutSlotWithBorders(s.X, s.Y, s.Width, s.Height,
+				s.Borders[0], s.Borders[1], s.Borders[2], s.Borders[3])
+			slot.SetFit(fit)
+			rule.AddSlot(slot)
+		}
+
+		layout.AddRule(rule, r.Sources)
+	}
+
+	return layout, nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+)
+
+//Watch checks the configuration file every interval and applies it when its
+//content changed. Invalid edits are rejected and the running show is left as
+//it is, onReload is called after every attempt with its error or nil. A new
+//Watch replaces the previous one
+func (i *Instance) Watch(path string, interval time.Duration, onReload func(err error)) {
+	i.StopWatching()
+
+	last, _ := ioutil.ReadFile(path)
+	done := make(chan struct{})
+
+	i.mutex.Lock()
+	i.watchDone = done
+	i.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			//editors may replace the file while saving, it's read again next time
+			data, err := ioutil.ReadFile(path)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+
+			c, err := parseFile(path, data)
+			if err == nil {
+				err = i.Apply(c)
+			}
+
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+}
+
+//StopWatching stops the Watch goroutine
+func (i *Instance) StopWatching() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.watchDone != nil {
+		close(i.watchDone)
+		i.watchDone = nil
+	}
+}
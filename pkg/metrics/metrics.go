@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+//Handler exports the stats of its compositors in the Prometheus text format,
+//mount it on a mux, e.g. mux.Handle("/metrics", handler)
+type Handler struct {
+	compositors map[string]*compositor.Compositor
+	mutex       sync.Mutex
+}
+
+//contentType is the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//NewHandler returns a handler with no compositors
+func NewHandler() *Handler {
+	return &Handler{compositors: make(map[string]*compositor.Compositor)}
+}
+
+//Add exports a compositor, its metrics are labelled with name
+func (h *Handler) Add(name string, c *compositor.Compositor) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.compositors[name] = c
+}
+
+//Remove stops exporting a compositor
+func (h *Handler) Remove(name string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.compositors, name)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	names := make([]string, 0, len(h.compositors))
+	for name := range h.compositors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compositors := make([]*compositor.Compositor, len(names))
+	for i, name := range names {
+		compositors[i] = h.compositors[name]
+	}
+	h.mutex.Unlock()
+
+	set := newMetricSet()
+	for i, c := range compositors {
+		collect(set, names[i], c)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	set.write(w)
+}
+
+//collect reads every metric of a compositor
+func collect(set *metricSet, name string, c *compositor.Compositor) {
+	labels := []string{"compositor", name}
+
+	set.gauge("gocompositor_state", "Compositor state, 1 null, 2 ready, 3 paused, 4 playing", labels, float64(c.State()))
+	set.gauge("gocompositor_sources", "Number of sources", labels, float64(c.SourceCount()))
+	set.counter("gocompositor_bus_errors_total", "Errors posted on the pipeline bus", labels, float64(c.BusErrors()))
+
+	output := c.OutputStats()
+	set.gauge("gocompositor_output_fps", "Frame rate of the composed canvas", labels, output.FPS)
+	set.counter("gocompositor_output_frames_total", "Frames of the composed canvas", labels, float64(output.Buffers))
+
+	if latency := c.Latency(); latency >= 0 {
+		set.gauge("gocompositor_latency_seconds", "Minimum pipeline latency", labels, latency.Seconds())
+	}
+
+	outputs := c.Outputs()
+	outputNames := make([]string, 0, len(outputs))
+	for output := range outputs {
+		outputNames = append(outputNames, output)
+	}
+	sort.Strings(outputNames)
+
+	for _, output := range outputNames {
+		stats := outputs[output]
+		labels := []string{"compositor", name, "output", output}
+
+		set.gauge("gocompositor_output_bitrate_bits", "Bitrate reaching a watched output in bits per second", labels, stats.Bitrate)
+		set.counter("gocompositor_output_bytes_total", "Bytes reaching a watched output", labels, float64(stats.Bytes))
+	}
+
+	for _, stats := range c.Stats() {
+		collectSource(set, name, stats)
+	}
+}
+
+func collectSource(set *metricSet, name string, stats element.SourceStats) {
+	labels := []string{"compositor", name, "source", stats.Source}
+
+	set.gauge("gocompositor_source_fps", "Decoded frame rate of a source", labels, stats.FPS)
+	set.gauge("gocompositor_source_bitrate_bits", "Encoded input bitrate of a source in bits per second", labels, stats.Bitrate)
+	set.gauge("gocompositor_source_width", "Decoded frame width of a source", labels, float64(stats.Width))
+	set.gauge("gocompositor_source_height", "Decoded frame height of a source", labels, float64(stats.Height))
+	set.counter("gocompositor_source_frames_total", "Decoded frames of a source", labels, float64(stats.Frames))
+	set.counter("gocompositor_source_bytes_total", "Encoded input bytes of a source", labels, float64(stats.Bytes))
+	set.counter("gocompositor_source_decode_errors_total", "Decoding errors of a source", labels, float64(stats.DecodeErrors))
+	set.counter("gocompositor_source_dropped_frames_total", "Input dropped by a source before decoding", labels, float64(stats.DroppedFrames))
+	set.counter("gocompositor_source_reconnects_total", "Reconnection attempts of a source", labels, float64(stats.Reconnects))
+
+	if stats.SinceLastBuffer >= 0 {
+		set.gauge("gocompositor_source_last_buffer_seconds", "Time since a source decoded a frame", labels, stats.SinceLastBuffer.Seconds())
+	}
+
+	if stats.RTP != nil {
+		set.counter("gocompositor_source_rtp_received_total", "RTP packets received by a source", labels, float64(stats.RTP.Received))
+		set.counter("gocompositor_source_rtp_lost_total", "RTP packets lost before a source", labels, float64(stats.RTP.Lost))
+		set.counter("gocompositor_source_rtp_reordered_total", "RTP packets a source received out of order", labels, float64(stats.RTP.Reordered))
+		set.gauge("gocompositor_source_rtp_jitter_seconds", "RTP interarrival jitter of a source", labels, float64(stats.RTP.Jitter)/float64(time.Second))
+	}
+}
+
+type sample struct {
+	labels []string
+	value  float64
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	samples []sample
+}
+
+//metricSet groups samples by metric, keeping the order metrics were first seen
+type metricSet struct {
+	families []*family
+	byName   map[string]*family
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{byName: make(map[string]*family)}
+}
+
+func (s *metricSet) gauge(name string, help string, labels []string, value float64) {
+	s.add(name, help, "gauge", labels, value)
+}
+
+func (s *metricSet) counter(name string, help string, labels []string, value float64) {
+	s.add(name, help, "counter", labels, value)
+}
+
+func (s *metricSet) add(name string, help string, kind string, labels []string, value float64) {
+	f, exists := s.byName[name]
+	if !exists {
+		f = &family{name: name, help: help, kind: kind}
+		s.byName[name] = f
+		s.families = append(s.families, f)
+	}
+
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+func (s *metricSet) write(w io.Writer) {
+	for _, f := range s.families {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+		for _, sample := range f.samples {
+			fmt.Fprintf(w, "%s%s %g\n", f.name, formatLabels(sample.labels), sample.value)
+		}
+	}
+}
+
+//formatLabels formats name and value pairs as {name="value",...}
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/config"
+)
+
+//CompositorRequest creates a compositor, see the "compositor" schema
+type CompositorRequest struct {
+	ID string `json:"id"`
+	//StatsInterval enables periodic stats events, e.g. "5s"
+	StatsInterval string `json:"stats_interval,omitempty"`
+}
+
+//StateRequest sets the compositor state, see the "state" schema
+type StateRequest struct {
+	State string `json:"state"`
+}
+
+//SourceRequest adds a source from the source registry, see the "source" schema
+type SourceRequest = config.Source
+
+//OutputRequest adds an output, see the "output" schema
+type OutputRequest = config.Output
+
+//LayoutRequest describes a layout, see the "layout" schema
+type LayoutRequest = config.Layout
+
+//RuleRequest holds the slots used when Sources sources are shown
+type RuleRequest = config.Rule
+
+//SlotRequest places one source, Borders are top, right, bottom and left
+type SlotRequest = config.Slot
+
+//SceneRequest registers a scene, see the "scene" schema
+type SceneRequest struct {
+	Name    string        `json:"name"`
+	Layout  LayoutRequest `json:"layout"`
+	Sources []string      `json:"sources"`
+}
+
+//SceneSwitchRequest switches scenes, see the "scene-switch" schema
+type SceneSwitchRequest struct {
+	Scene      string `json:"scene"`
+	Transition string `json:"transition,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+//CompositorState is returned by the compositor endpoints
+type CompositorState struct {
+	ID      string        `json:"id"`
+	State   string        `json:"state"`
+	Scene   string        `json:"scene,omitempty"`
+	Sources []SourceState `json:"sources"`
+	Outputs []OutputState `json:"outputs"`
+}
+
+//SourceState describes a source
+type SourceState struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+//OutputState describes an output
+type OutputState struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Location string `json:"location,omitempty"`
+}
+
+//StatsResponse is returned by the stats endpoint
+type StatsResponse struct {
+	State     string                 `json:"state"`
+	Sources   int                    `json:"sources"`
+	OutputFPS float64                `json:"output_fps"`
+	BusErrors uint64                 `json:"bus_errors"`
+	LatencyMS float64                `json:"latency_ms"`
+	Source    map[string]SourceStats `json:"source"`
+	Output    map[string]OutputStats `json:"output"`
+}
+
+//SourceStats are the stats of a source
+type SourceStats struct {
+	Width             int       `json:"width"`
+	Height            int       `json:"height"`
+	FPS               float64   `json:"fps"`
+	Bitrate           float64   `json:"bitrate"`
+	Frames            uint64    `json:"frames"`
+	Bytes             uint64    `json:"bytes"`
+	SinceLastBufferMS float64   `json:"since_last_buffer_ms"`
+	DecodeErrors      uint64    `json:"decode_errors"`
+	DroppedFrames     uint64    `json:"dropped_frames"`
+	Reconnects        uint64    `json:"reconnects"`
+	RTP               *RTPStats `json:"rtp,omitempty"`
+}
+
+//RTPStats are the packet counters of RTP sources
+type RTPStats struct {
+	Received  uint64  `json:"received"`
+	Lost      uint64  `json:"lost"`
+	Reordered uint64  `json:"reordered"`
+	Dropped   uint64  `json:"dropped"`
+	JitterMS  float64 `json:"jitter_ms"`
+}
+
+//OutputStats are the stats of an output
+type OutputStats struct {
+	Bitrate float64 `json:"bitrate"`
+	Bytes   uint64  `json:"bytes"`
+}
+
+//Event is sent on the event stream
+type Event struct {
+	Type    string       `json:"type"`
+	Source  string       `json:"source"`
+	Time    time.Time    `json:"time"`
+	Message string       `json:"message,omitempty"`
+	Stats   *SourceStats `json:"stats,omitempty"`
+}
+
+//ErrorResponse is returned with every 4xx and 5xx status
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newSourceStats(stats element.SourceStats) SourceStats {
+	s := SourceStats{
+		Width:             stats.Width,
+		Height:            stats.Height,
+		FPS:               stats.FPS,
+		Bitrate:           stats.Bitrate,
+		Frames:            stats.Frames,
+		Bytes:             stats.Bytes,
+		SinceLastBufferMS: -1,
+		DecodeErrors:      stats.DecodeErrors,
+		DroppedFrames:     stats.DroppedFrames,
+		Reconnects:        stats.Reconnects,
+	}
+
+	if stats.SinceLastBuffer >= 0 {
+		s.SinceLastBufferMS = milliseconds(stats.SinceLastBuffer)
+	}
+
+	if stats.RTP != nil {
+		s.RTP = &RTPStats{
+			Received:  stats.RTP.Received,
+			Lost:      stats.RTP.Lost,
+			Reordered: stats.RTP.Reordered,
+			Dropped:   stats.RTP.Dropped,
+			JitterMS:  milliseconds(stats.RTP.Jitter),
+		}
+	}
+
+	return s
+}
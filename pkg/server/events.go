@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+//subscriberBuffer is how many events a slow stream can fall behind before
+//events are dropped for it
+const subscriberBuffer = 64
+
+//hub fans compositor events out to the Server-Sent Events streams
+type hub struct {
+	sourceID    func(name string) string
+	subscribers map[chan Event]struct{}
+	closed      chan struct{}
+	isClosed    bool
+
+	mutex sync.Mutex
+}
+
+func newHub(sourceID func(name string) string) *hub {
+	return &hub{
+		sourceID:    sourceID,
+		subscribers: make(map[chan Event]struct{}),
+		closed:      make(chan struct{}),
+	}
+}
+
+//publish is the compositor event handler, it never blocks on a stream
+func (h *hub) publish(event element.Event) {
+	e := Event{
+		Type:    string(event.Type),
+		Source:  h.sourceID(event.Source),
+		Time:    event.Time,
+		Message: event.Message,
+	}
+
+	if event.Stats != nil {
+		stats := newSourceStats(*event.Stats)
+		e.Stats = &stats
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscriber := range h.subscribers {
+		select {
+		case subscriber <- e:
+		default:
+		}
+	}
+}
+
+func (h *hub) subscribe() (chan Event, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.isClosed {
+		return nil, false
+	}
+
+	subscriber := make(chan Event, subscriberBuffer)
+	h.subscribers[subscriber] = struct{}{}
+
+	return subscriber, true
+}
+
+func (h *hub) unsubscribe(subscriber chan Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.subscribers, subscriber)
+}
+
+//close ends every stream
+func (h *hub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.isClosed {
+		h.isClosed = true
+		close(h.closed)
+	}
+}
+
+//serve streams events as Server-Sent Events until the client goes away or
+//the compositor is destroyed, the SSE event name is the event type
+func (h *hub) serve(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Streaming unsupported"})
+		return
+	}
+
+	subscriber, ok := h.subscribe()
+	if !ok {
+		writeError(w, ErrNotFound)
+		return
+	}
+	defer h.unsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.closed:
+			return
+		case event := <-subscriber:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
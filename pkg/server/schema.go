@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+)
+
+//Schemas are the JSON schemas of the request bodies, by name. They are
+//served under /schemas/<name>
+var Schemas = map[string]string{
+	"compositor": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Compositor",
+  "type": "object",
+  "required": ["id"],
+  "additionalProperties": false,
+  "properties": {
+    "id": {"type": "string", "minLength": 1},
+    "stats_interval": {"type": "string", "description": "Go duration between stats events, e.g. 5s"}
+  }
+}`,
+	"state": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "State",
+  "type": "object",
+  "required": ["state"],
+  "additionalProperties": false,
+  "properties": {
+    "state": {"enum": ["null", "paused", "playing"]}
+  }
+}`,
+	"source": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Source",
+  "type": "object",
+  "required": ["id", "type"],
+  "additionalProperties": false,
+  "properties": {
+    "id": {"type": "string", "minLength": 1},
+    "type": {"type": "string", "description": "A registered source type, see /source-types"},
+    "width": {"type": "integer", "minimum": 0},
+    "height": {"type": "integer", "minimum": 0},
+    "config": {"type": "object", "description": "Parameters of the source type"}
+  }
+}`,
+	"output": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Output",
+  "type": "object",
+  "required": ["id", "type"],
+  "additionalProperties": false,
+  "properties": {
+    "id": {"type": "string", "minLength": 1},
+    "type": {"enum": ["fake", "display", "file", "rtmp"]},
+    "location": {"type": "string", "description": "File path or RTMP url"},
+    "bitrate": {"type": "integer", "minimum": 0, "description": "kbit/s"}
+  }
+}`,
+	"layout": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Layout",
+  "type": "object",
+  "required": ["width", "height", "rules"],
+  "additionalProperties": false,
+  "properties": {
+    "width": {"type": "integer", "minimum": 1},
+    "height": {"type": "integer", "minimum": 1},
+    "rules": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["sources", "slots"],
+        "additionalProperties": false,
+        "properties": {
+          "sources": {"type": "integer", "minimum": 1},
+          "slots": {
+            "type": "array",
+            "description": "As many slots as sources",
+            "items": {
+              "type": "object",
+              "required": ["x", "y", "width", "height"],
+              "additionalProperties": false,
+              "properties": {
+                "x": {"type": "integer"},
+                "y": {"type": "integer"},
+                "width": {"type": "integer", "minimum": 1},
+                "height": {"type": "integer", "minimum": 1},
+                "borders": {"type": "array", "items": {"type": "integer"}, "maxItems": 4, "description": "top, right, bottom, left"},
+                "fit": {"enum": ["fill", "contain", "cover"]}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`,
+	"scene": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Scene",
+  "type": "object",
+  "required": ["name", "layout", "sources"],
+  "additionalProperties": false,
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "layout": {"$ref": "layout"},
+    "sources": {"type": "array", "items": {"type": "string"}, "description": "Source ids"}
+  }
+}`,
+	"scene-switch": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Scene switch",
+  "type": "object",
+  "required": ["scene"],
+  "additionalProperties": false,
+  "properties": {
+    "scene": {"type": "string"},
+    "transition": {"enum": ["cut", "fade"]},
+    "duration": {"type": "string", "description": "Go duration of fades, e.g. 500ms"}
+  }
+}`,
+}
+
+func serveSchemas(w http.ResponseWriter, r *http.Request, parts []string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if len(parts) == 0 {
+		names := make([]string, 0, len(Schemas))
+		for name := range Schemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		writeJSON(w, http.StatusOK, names)
+		return
+	}
+
+	schema, ok := Schemas[parts[0]]
+	if !ok {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write([]byte(schema))
+}
@@ -0,0 +1,773 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/config"
+	gstreamer "github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//Server is a REST control API for compositors. It is a http.Handler, mount
+//it on a mux with http.StripPrefix to serve it under a prefix
+type Server struct {
+	compositors map[string]*entry
+	mutex       sync.Mutex
+}
+
+//entry is a compositor with the sources and outputs created through the API
+type entry struct {
+	id         string
+	compositor *compositor.Compositor
+	hub        *hub
+
+	sources map[string]*source
+	order   []string
+	outputs map[string]OutputRequest
+
+	mutex sync.Mutex
+
+	//names maps source element names to their ids for the compositor events,
+	//they are published from streaming threads while mutex may be held
+	names      map[string]string
+	namesMutex sync.Mutex
+}
+
+type source struct {
+	request SourceRequest
+	video   element.Video
+}
+
+var (
+	ErrNotFound   = errors.New("Not found")
+	ErrExists     = errors.New("Already exists")
+	ErrID         = errors.New("An id is required")
+	ErrState      = errors.New("Unknown state")
+	ErrFit        = config.ErrFit
+	ErrTransition = errors.New("Unknown transition")
+	ErrLayout     = config.ErrLayout
+)
+
+var states = map[string]gstreamer.GstState{
+	"null":    gstreamer.GstStateNull,
+	"ready":   gstreamer.GstStateReady,
+	"paused":  gstreamer.GstStatePaused,
+	"playing": gstreamer.GstStatePlaying,
+}
+
+//NewServer returns a server without compositors
+func NewServer() *Server {
+	return &Server{compositors: make(map[string]*entry)}
+}
+
+//httpError carries the status an error is answered with
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(err error) error {
+	return &httpError{status: http.StatusBadRequest, err: err}
+}
+
+func statusOf(err error) int {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		return httpErr.status
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound),
+		errors.Is(err, compositor.ErrSceneNotFound),
+		errors.Is(err, compositor.ErrOutputNotFound),
+		errors.Is(err, compositor.ErrVideoNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrExists),
+		errors.Is(err, compositor.ErrSceneExists),
+		errors.Is(err, compositor.ErrOutputExists):
+		return http.StatusConflict
+	case errors.Is(err, element.ErrSourceTypeUnknown),
+		errors.Is(err, element.ErrSourceParam),
+		errors.Is(err, compositor.ErrOutputType),
+		errors.Is(err, compositor.ErrOutputLocation):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, statusOf(err), ErrorResponse{Error: err.Error()})
+}
+
+//decode reads a JSON body, unknown fields are rejected
+func decode(r *http.Request, value interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		return badRequest(err)
+	}
+
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case parts[0] == "compositors" && len(parts) == 1:
+		s.serveCompositors(w, r)
+	case parts[0] == "compositors":
+		s.serveCompositor(w, r, parts[1:])
+	case parts[0] == "schemas" && len(parts) <= 2:
+		serveSchemas(w, r, parts[1:])
+	case parts[0] == "source-types" && len(parts) == 1:
+		serveSourceTypes(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "Method not allowed"})
+}
+
+func (s *Server) serveCompositors(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.list())
+	case http.MethodPost:
+		var request CompositorRequest
+		if err := decode(r, &request); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		e, err := s.create(request)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, e.state())
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) serveCompositor(w http.ResponseWriter, r *http.Request, parts []string) {
+	e, ok := s.lookup(parts[0])
+	if !ok {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	resource := ""
+	if len(parts) > 1 {
+		resource = parts[1]
+	}
+
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, e.state())
+		case http.MethodDelete:
+			s.destroy(e)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case resource == "state" && len(parts) == 2:
+		e.serveState(w, r)
+	case resource == "sources" && len(parts) <= 3:
+		e.serveSources(w, r, parts[2:])
+	case resource == "outputs" && len(parts) <= 3:
+		e.serveOutputs(w, r, parts[2:])
+	case resource == "layout" && len(parts) == 2:
+		e.serveLayout(w, r)
+	case resource == "scenes" && len(parts) <= 3:
+		e.serveScenes(w, r, parts[2:])
+	case resource == "scene" && len(parts) == 2:
+		e.serveScene(w, r)
+	case resource == "stats" && len(parts) == 2:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeJSON(w, http.StatusOK, e.stats())
+	case resource == "events" && len(parts) == 2:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		e.hub.serve(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) list() []CompositorState {
+	s.mutex.Lock()
+	ids := make([]string, 0, len(s.compositors))
+	for id := range s.compositors {
+		ids = append(ids, id)
+	}
+	s.mutex.Unlock()
+	sort.Strings(ids)
+
+	list := make([]CompositorState, 0, len(ids))
+	for _, id := range ids {
+		if e, ok := s.lookup(id); ok {
+			list = append(list, e.state())
+		}
+	}
+
+	return list
+}
+
+func (s *Server) lookup(id string) (*entry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.compositors[id]
+	return e, ok
+}
+
+func (s *Server) create(request CompositorRequest) (*entry, error) {
+	if request.ID == "" {
+		return nil, badRequest(ErrID)
+	}
+
+	var interval time.Duration
+	if request.StatsInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(request.StatsInterval); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.compositors[request.ID]; exists {
+		return nil, ErrExists
+	}
+
+	cmp, err := compositor.NewCompositor()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{
+		id:         request.ID,
+		compositor: cmp,
+		sources:    make(map[string]*source),
+		outputs:    make(map[string]OutputRequest),
+		names:      make(map[string]string),
+	}
+	e.hub = newHub(e.sourceID)
+	cmp.OnEvent(e.hub.publish)
+	cmp.SetStatsInterval(interval)
+
+	s.compositors[request.ID] = e
+
+	return e, nil
+}
+
+//destroy stops the compositor and ends its event streams
+func (s *Server) destroy(e *entry) {
+	s.mutex.Lock()
+	delete(s.compositors, e.id)
+	s.mutex.Unlock()
+
+	e.compositor.SetStatsInterval(0)
+	e.compositor.Stop()
+	e.hub.close()
+}
+
+func stateName(state gstreamer.GstState) string {
+	for name, s := range states {
+		if s == state {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("%d", state)
+}
+
+func (e *entry) state() CompositorState {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	state := CompositorState{
+		ID:      e.id,
+		State:   stateName(e.compositor.State()),
+		Scene:   e.compositor.CurrentScene(),
+		Sources: make([]SourceState, 0, len(e.order)),
+		Outputs: make([]OutputState, 0, len(e.outputs)),
+	}
+
+	for _, id := range e.order {
+		src := e.sources[id]
+		state.Sources = append(state.Sources, SourceState{
+			ID:     id,
+			Type:   src.request.Type,
+			Name:   src.video.Name(),
+			Width:  src.request.Width,
+			Height: src.request.Height,
+		})
+	}
+
+	for id, output := range e.outputs {
+		state.Outputs = append(state.Outputs, OutputState{ID: id, Type: output.Type, Location: output.Location})
+	}
+	sort.Slice(state.Outputs, func(i, j int) bool { return state.Outputs[i].ID < state.Outputs[j].ID })
+
+	return state
+}
+
+//sourceID maps a source element name to the id it was created with
+func (e *entry) sourceID(name string) string {
+	e.namesMutex.Lock()
+	defer e.namesMutex.Unlock()
+
+	if id, ok := e.names[name]; ok {
+		return id
+	}
+
+	return name
+}
+
+//setSourceID records the id of a source element name, an empty id forgets it
+func (e *entry) setSourceID(name string, id string) {
+	e.namesMutex.Lock()
+	defer e.namesMutex.Unlock()
+
+	if id == "" {
+		delete(e.names, name)
+		return
+	}
+
+	e.names[name] = id
+}
+
+func (e *entry) serveState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, http.MethodPut)
+		return
+	}
+
+	var request StateRequest
+	if err := decode(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch states[request.State] {
+	case gstreamer.GstStatePlaying:
+		e.compositor.Start()
+	case gstreamer.GstStatePaused:
+		e.compositor.Pause()
+	case gstreamer.GstStateNull:
+		e.compositor.Stop()
+	default:
+		writeError(w, badRequest(ErrState))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, e.state())
+}
+
+func (e *entry) serveSources(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 1 {
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, http.MethodDelete)
+			return
+		}
+
+		if err := e.removeSource(parts[0]); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, e.state().Sources)
+	case http.MethodPost:
+		var request SourceRequest
+		if err := decode(r, &request); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := e.addSource(request); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, e.state())
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (e *entry) addSource(request SourceRequest) error {
+	if request.ID == "" {
+		return badRequest(ErrID)
+	}
+
+	if request.Width <= 0 {
+		request.Width = config.DefaultSourceWidth
+	}
+	if request.Height <= 0 {
+		request.Height = config.DefaultSourceHeight
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, exists := e.sources[request.ID]; exists {
+		return ErrExists
+	}
+
+	video, err := element.NewSource(request.Type, request.Width, request.Height, request.Config)
+	if err != nil {
+		return err
+	}
+
+	//the source can publish events as soon as it is added
+	e.setSourceID(video.Name(), request.ID)
+	if err := e.compositor.AddVideo(video); err != nil {
+		e.setSourceID(video.Name(), "")
+		return err
+	}
+
+	e.sources[request.ID] = &source{request: request, video: video}
+	e.order = append(e.order, request.ID)
+
+	return nil
+}
+
+func (e *entry) removeSource(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	src, ok := e.sources[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := e.compositor.RemoveVideo(src.video); err != nil {
+		return err
+	}
+
+	e.setSourceID(src.video.Name(), "")
+	delete(e.sources, id)
+	for i, sourceID := range e.order {
+		if sourceID == id {
+			e.order = append(e.order[:i], e.order[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (e *entry) serveOutputs(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 1 {
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, http.MethodDelete)
+			return
+		}
+
+		if err := e.removeOutput(parts[0]); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, e.state().Outputs)
+	case http.MethodPost:
+		var request OutputRequest
+		if err := decode(r, &request); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := e.addOutput(request); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, e.state())
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (e *entry) addOutput(request OutputRequest) error {
+	if request.ID == "" {
+		return badRequest(ErrID)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, err := e.compositor.AddOutput(request.ID, compositor.OutputOptions{
+		Type:     compositor.OutputType(request.Type),
+		Location: request.Location,
+		Bitrate:  request.Bitrate,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.outputs[request.ID] = request
+
+	return nil
+}
+
+func (e *entry) removeOutput(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if err := e.compositor.RemoveOutput(id); err != nil {
+		return err
+	}
+
+	delete(e.outputs, id)
+
+	return nil
+}
+
+func (e *entry) serveLayout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, http.MethodPut)
+		return
+	}
+
+	var request LayoutRequest
+	if err := decode(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	layout, err := newLayout(request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	e.compositor.SetLayout(layout)
+	writeJSON(w, http.StatusOK, e.state())
+}
+
+//newLayout builds a compositor layout, layout errors are bad requests
+func newLayout(request LayoutRequest) (*compositor.Layout, error) {
+	layout, err := config.NewLayout(request)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	return layout, nil
+}
+
+func (e *entry) serveScenes(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 1 {
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, http.MethodDelete)
+			return
+		}
+
+		if err := e.compositor.RemoveScene(parts[0]); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var request SceneRequest
+	if err := decode(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := e.addScene(request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, e.state())
+}
+
+func (e *entry) addScene(request SceneRequest) error {
+	if request.Name == "" {
+		return badRequest(ErrID)
+	}
+
+	layout, err := newLayout(request.Layout)
+	if err != nil {
+		return err
+	}
+
+	scene := compositor.NewScene(request.Name, layout)
+
+	e.mutex.Lock()
+	for _, id := range request.Sources {
+		src, ok := e.sources[id]
+		if !ok {
+			e.mutex.Unlock()
+			return badRequest(fmt.Errorf("Unknown source %s", id))
+		}
+
+		scene.AddVideo(src.video)
+	}
+	e.mutex.Unlock()
+
+	return e.compositor.AddScene(scene)
+}
+
+func (e *entry) serveScene(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, http.MethodPut)
+		return
+	}
+
+	var request SceneSwitchRequest
+	if err := decode(r, &request); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	transition := compositor.Transition{Type: compositor.TransitionCut}
+	switch request.Transition {
+	case "", "cut":
+	case "fade":
+		transition.Type = compositor.TransitionFade
+	default:
+		writeError(w, badRequest(ErrTransition))
+		return
+	}
+
+	if request.Duration != "" {
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil {
+			writeError(w, badRequest(err))
+			return
+		}
+		transition.Duration = duration
+	}
+
+	if err := e.compositor.SwitchSceneWithTransition(request.Scene, transition); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, e.state())
+}
+
+func (e *entry) stats() StatsResponse {
+	cmp := e.compositor
+	response := StatsResponse{
+		State:     stateName(cmp.State()),
+		Sources:   cmp.SourceCount(),
+		OutputFPS: cmp.OutputStats().FPS,
+		BusErrors: cmp.BusErrors(),
+		LatencyMS: -1,
+		Source:    make(map[string]SourceStats),
+		Output:    make(map[string]OutputStats),
+	}
+
+	if latency := cmp.Latency(); latency >= 0 {
+		response.LatencyMS = milliseconds(latency)
+	}
+
+	for _, stats := range cmp.Stats() {
+		response.Source[e.sourceID(stats.Source)] = newSourceStats(stats)
+	}
+
+	for name, stats := range cmp.Outputs() {
+		response.Output[name] = OutputStats{Bitrate: stats.Bitrate, Bytes: stats.Bytes}
+	}
+
+	return response
+}
+
+func serveSourceTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	type param struct {
+		Name        string      `json:"name"`
+		Type        string      `json:"type"`
+		Required    bool        `json:"required,omitempty"`
+		Default     interface{} `json:"default,omitempty"`
+		Description string      `json:"description,omitempty"`
+	}
+
+	type sourceType struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description,omitempty"`
+		Params      []param `json:"params"`
+	}
+
+	types := make([]sourceType, 0)
+	for _, t := range element.SourceTypes() {
+		st := sourceType{Name: t.Name, Description: t.Description, Params: make([]param, 0, len(t.Params))}
+		for _, p := range t.Params {
+			st.Params = append(st.Params, param{
+				Name:        p.Name,
+				Type:        string(p.Type),
+				Required:    p.Required,
+				Default:     p.Default,
+				Description: p.Description,
+			})
+		}
+		types = append(types, st)
+	}
+
+	writeJSON(w, http.StatusOK, types)
+}
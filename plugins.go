@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+)
+
+var ErrMissingPlugins = errors.New("Required GStreamer plugins are missing")
+
+func pluginsCommand(args []string) error {
+	flags := flag.NewFlagSet("plugins", flag.ExitOnError)
+	flags.Parse(args)
+
+	missingRequired := false
+	for _, plugin := range compositor.Plugins {
+		missing := plugin.Missing()
+
+		status := "ok"
+		if len(missing) > 0 {
+			status = "missing " + strings.Join(missing, ", ")
+			if !plugin.Optional {
+				missingRequired = true
+			}
+		}
+
+		kind := "required"
+		if plugin.Optional {
+			kind = "optional"
+		}
+
+		fmt.Printf("%-16s %-9s %s\n", plugin.Name, kind, status)
+	}
+
+	if missingRequired {
+		return ErrMissingPlugins
+	}
+
+	return nil
+}
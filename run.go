@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/config"
+	"github.com/vinijabes/gocompositor/pkg/metrics"
+)
+
+//stringsFlag is a flag that can be repeated
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+var (
+	ErrNoConfig      = errors.New("At least one --config is required")
+	ErrDuplicateName = errors.New("Two configurations have the same name")
+)
+
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+
+	var paths stringsFlag
+	flags.Var(&paths, "config", "configuration `file`, repeat it to run several compositors")
+	listen := flags.String("listen", "", "`address` serving Prometheus metrics on /metrics, e.g. :9090")
+	timeout := flags.Duration("shutdown-timeout", 5*time.Second, "how long outputs may take to finish on shutdown")
+	watch := flags.Duration("watch", time.Second, "how often configuration files are checked for changes, 0 disables reloading")
+	flags.Parse(args)
+
+	if len(paths) == 0 {
+		flags.Usage()
+		return ErrNoConfig
+	}
+
+	instances, err := buildAll(paths)
+	if err != nil {
+		return err
+	}
+
+	handler := metrics.NewHandler()
+	for i, instance := range instances {
+		handler.Add(instance.Name(), instance.Compositor)
+		instance.Compositor.Start()
+		log.Printf("Started %s", instance.Name())
+
+		if *watch > 0 {
+			path := paths[i]
+			instance.Watch(path, *watch, func(err error) {
+				if err != nil {
+					log.Printf("Rejected %s: %s", path, err)
+				} else {
+					log.Printf("Applied %s", path)
+				}
+			})
+		}
+	}
+
+	var server *http.Server
+	if *listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler)
+		server = &http.Server{Addr: *listen, Handler: mux}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Println(err)
+			}
+		}()
+		log.Printf("Serving metrics on %s/metrics", *listen)
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	log.Printf("Received %s, shutting down", <-signals)
+
+	go func() {
+		<-signals
+		log.Println("Received a second signal, stopping now")
+		for _, instance := range instances {
+			instance.Compositor.Stop()
+		}
+		os.Exit(1)
+	}()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		server.Shutdown(ctx)
+		cancel()
+	}
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance *config.Instance) {
+			defer wg.Done()
+
+			instance.StopWatching()
+			if !instance.Compositor.Shutdown(*timeout) {
+				log.Printf("%s didn't finish in %s, stopped it", instance.Name(), *timeout)
+			}
+		}(instance)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+//buildAll loads every configuration before building any compositor, so a
+//bad file doesn't leave the others half started
+func buildAll(paths []string) ([]*config.Instance, error) {
+	configs := make([]*config.Config, len(paths))
+	names := make(map[string]bool)
+
+	for i, path := range paths {
+		c, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: %w: %s", path, ErrDuplicateName, c.Name)
+		}
+		names[c.Name] = true
+
+		configs[i] = c
+	}
+
+	instances := make([]*config.Instance, 0, len(configs))
+	for i, c := range configs {
+		instance, err := config.Build(c)
+		if err != nil {
+			for _, built := range instances {
+				built.Compositor.Stop()
+			}
+			return nil, fmt.Errorf("%s: %w", paths[i], err)
+		}
+
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
@@ -6,6 +6,9 @@ import (
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
+
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
 )
 
 // assert fails the test if the condition is false.
@@ -34,3 +37,75 @@ func equals(tb testing.TB, exp, act interface{}) {
 		tb.FailNow()
 	}
 }
+
+// requireElements skips the test when a GStreamer element isn't installed.
+func requireElements(tb testing.TB, factories ...string) {
+	for _, name := range factories {
+		if _, err := gstreamer.NewElementFactory(name); err != nil {
+			tb.Skipf("%s is not installed", name)
+		}
+	}
+}
+
+// newTestPipeline links one element of each factory in a new pipeline, the
+// test is skipped when one of them isn't installed.
+func newTestPipeline(tb testing.TB, name string, factories ...string) (gstreamer.Pipeline, []gstreamer.Element) {
+	requireElements(tb, factories...)
+
+	pipeline, err := gstreamer.NewPipeline(name)
+	ok(tb, err)
+
+	var elements []gstreamer.Element
+	for i, factory := range factories {
+		e, err := gstreamer.NewElement(factory, fmt.Sprintf("%s_%s_%d", name, factory, i))
+		ok(tb, err)
+		assert(tb, pipeline.Add(e), "failed to add %s", factory)
+
+		if i > 0 {
+			assert(tb, elements[i-1].Link(e), "failed to link %s", factory)
+		}
+		elements = append(elements, e)
+	}
+
+	return pipeline, elements
+}
+
+// runToEOS plays a pipeline until its end of stream and fails the test on error.
+func runToEOS(tb testing.TB, pipeline gstreamer.Pipeline, timeout time.Duration) {
+	bus, err := pipeline.GetBus()
+	ok(tb, err)
+
+	pipeline.SetState(gstreamer.GstStatePlaying)
+	defer pipeline.SetState(gstreamer.GstStateNull)
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for bus.HavePending() {
+			message, err := bus.Pop()
+			ok(tb, err)
+
+			switch message.GetType() {
+			case gstreamer.MessageEOS:
+				return
+			case gstreamer.MessageError:
+				tb.Fatalf("%s failed: %v", pipeline.GetName(), message.GetStructure())
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tb.Fatalf("%s didn't end in time", pipeline.GetName())
+}
+
+// firstElement returns the first installed factory, the test is skipped when
+// none of them is.
+func firstElement(tb testing.TB, factories ...string) string {
+	for _, name := range factories {
+		if _, err := gstreamer.NewElementFactory(name); err == nil {
+			return name
+		}
+	}
+
+	tb.Skipf("none of %v is installed", factories)
+	return ""
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestClockOverlay(t *testing.T) {
+	video, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+
+	clock := video.Clock()
+	assert(t, !clock.Visible(), "clocks start hidden")
+
+	options := clock.Options()
+	options.Text = "Gate"
+	options.Timezone = "Mars/Olympus"
+	assert(t, clock.Configure(options) != nil, "unknown timezones must be rejected")
+
+	var mutex sync.Mutex
+	now := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	clock.SetTimeSource(func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return now
+	})
+
+	options.Timezone = "America/Sao_Paulo"
+	options.Format = "2006-01-02 15:04"
+	ok(t, clock.Configure(options))
+	equals(t, "Gate 2021-03-14 12:09", clock.Text())
+
+	options.Mode = element.ClockElapsedTime
+	options.Text = ""
+	ok(t, clock.Configure(options))
+	clock.SetVisible(true)
+	defer clock.SetVisible(false)
+	equals(t, "0:00:00", clock.Text())
+
+	mutex.Lock()
+	now = now.Add(time.Hour + 2*time.Minute + 5*time.Second)
+	mutex.Unlock()
+	equals(t, "1:02:05", clock.Text())
+}
+
+func TestClockStartStop(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+
+	//every tick reads the time source
+	var mutex sync.Mutex
+	ticks := 0
+	clock := video.Clock()
+	clock.SetTimeSource(func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		ticks++
+		return time.Unix(0, 0)
+	})
+	count := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return ticks
+	}
+
+	options := clock.Options()
+	options.Interval = 10 * time.Millisecond
+	ok(t, clock.Configure(options))
+	clock.SetVisible(true)
+	defer clock.SetVisible(false)
+
+	//an added source with a visible clock ticks
+	ok(t, cmp.AddVideo(video))
+	started := count()
+	deadline := time.Now().Add(time.Second)
+	for count() < started+3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert(t, count() >= started+3, "the clock didn't tick after the source was added")
+
+	//a removed source doesn't tick but keeps the visibility the user chose
+	ok(t, cmp.RemoveVideo(video))
+	stopped := count()
+	time.Sleep(50 * time.Millisecond)
+	equals(t, stopped, count())
+	assert(t, clock.Visible(), "the clock should still be visible")
+}
+
+func TestCanvasClock(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	clock := cmp.Clock()
+	clock.SetVisible(true)
+	assert(t, clock.Visible(), "canvas clock should be visible")
+	clock.SetVisible(false)
+}
@@ -1,8 +1,106 @@
 package tests
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
 )
 
 func TestCompositor(t *testing.T) {
 }
+
+func TestCompositorRemoveWhilePlaying(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	bars, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+	ok(t, cmp.AddVideo(bars))
+
+	camera, err := element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{
+		Location: "rtsp://127.0.0.1:8554/test",
+		Audio:    true,
+	})
+	ok(t, err)
+	ok(t, cmp.AddVideo(camera))
+	ok(t, cmp.AddAudio(camera.Audio()))
+
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	cmp.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	ok(t, cmp.RemoveVideo(camera))
+	equals(t, element.Videos{bars}, cmp.Videos())
+	equals(t, compositor.ErrAudioNotFound, cmp.RemoveAudio(camera.Audio()))
+
+	ok(t, cmp.RemoveOutput("null"))
+	_, found := cmp.Output("null")
+	assert(t, !found, "the output should be removed")
+
+	//the compositor keeps going with what is left
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+	ok(t, cmp.RemoveVideo(bars))
+}
+
+func TestCompositorRemoveFileOutput(t *testing.T) {
+	requireElements(t, "videotestsrc", "x264enc", "h264parse", "matroskamux")
+
+	dir, err := ioutil.TempDir("", "output")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	video, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	location := filepath.Join(dir, "show.mkv")
+	_, err = cmp.AddOutput("rec", compositor.OutputOptions{Type: compositor.OutputFile, Location: location})
+	ok(t, err)
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	cmp.Start()
+	time.Sleep(time.Second)
+	ok(t, cmp.RemoveOutput("rec"))
+
+	//matroskamux writes its Cues index on EOS only
+	data, err := ioutil.ReadFile(location)
+	ok(t, err)
+	assert(t, bytes.Contains(data, []byte{0x1c, 0x53, 0xbb, 0x6b}), "the file should be finalized")
+}
+
+func TestCompositorDescribe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoImage(640, 360, writeTestPNG(t, dir, 320, 180))
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	//the picture is decoded in its own pipeline, named after the source id
+	player := "player_" + strings.TrimPrefix(video.Name(), "source_")
+	assert(t, strings.Contains(cmp.Describe(false), player), "the description should include %s", player)
+	equals(t, 2, strings.Count(cmp.Dot(), "digraph"))
+
+	ok(t, cmp.RemoveVideo(video))
+	assert(t, !strings.Contains(cmp.Describe(false), player), "removed sources shouldn't be described")
+}
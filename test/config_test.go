@@ -0,0 +1,264 @@
+package tests
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/config"
+)
+
+const testConfig = `{
+  "name": "show",
+  "sources": [
+    {"id": "cam", "type": "test", "config": {"pattern": 18}},
+    {"id": "bars", "type": "test"}
+  ],
+  "layout": {
+    "width": 1280,
+    "height": 720,
+    "rules": [
+      {"sources": 1, "slots": [{"x": 0, "y": 0, "width": 1280, "height": 720}]},
+      {"sources": 2, "slots": [{"x": 0, "y": 0, "width": 640, "height": 360}, {"x": 640, "y": 0, "width": 640, "height": 360, "fit": "cover"}]}
+    ]
+  },
+  "outputs": [{"id": "null", "type": "fake"}]
+}`
+
+func TestConfigBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "show.json")
+	ok(t, ioutil.WriteFile(path, []byte(testConfig), 0644))
+
+	c, err := config.Load(path)
+	ok(t, err)
+	equals(t, "show", c.Name)
+	equals(t, 2, len(c.Sources))
+
+	instance, err := config.Build(c)
+	ok(t, err)
+	defer instance.Compositor.Stop()
+
+	equals(t, 2, instance.Compositor.SourceCount())
+	_, found := instance.Compositor.Output("null")
+	assert(t, found, "outputs should be added")
+	_, found = instance.Source("cam")
+	assert(t, found, "sources should be kept by id")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		err    error
+	}{
+		{"unknown field", `{"name": "x", "color": "red"}`, nil},
+		{"source type", `{"sources": [{"id": "a", "type": "nope"}]}`, element.ErrSourceTypeUnknown},
+		{"source param", `{"sources": [{"id": "a", "type": "test", "config": {"speed": 1}}]}`, element.ErrSourceParam},
+		{"duplicate", `{"sources": [{"id": "a", "type": "test"}, {"id": "a", "type": "test"}]}`, config.ErrDuplicate},
+		{"output location", `{"outputs": [{"id": "rec", "type": "file"}]}`, compositor.ErrOutputLocation},
+		{"output type", `{"outputs": [{"id": "out", "type": "tape"}]}`, compositor.ErrOutputType},
+		{"slots", `{"layout": {"width": 1280, "height": 720, "rules": [{"sources": 2, "slots": []}]}}`, config.ErrLayout},
+		{"slot bounds", `{"layout": {"width": 1280, "height": 720, "rules": [{"sources": 1, "slots": [{"x": 1000, "y": 0, "width": 640, "height": 360}]}]}}`, config.ErrSlot},
+		{"fit", `{"layout": {"width": 1280, "height": 720, "rules": [{"sources": 1, "slots": [{"x": 0, "y": 0, "width": 640, "height": 360, "fit": "stretch"}]}]}}`, config.ErrFit},
+		{"missing rule", `{"sources": [{"id": "a", "type": "test"}], "layout": {"width": 1280, "height": 720, "rules": []}}`, config.ErrNoRule},
+	}
+
+	for _, c := range cases {
+		_, err := config.Parse([]byte(c.config))
+		assert(t, err != nil, "%s should be rejected", c.name)
+
+		if c.err != nil {
+			assert(t, errors.Is(err, c.err), "%s: unexpected error %v", c.name, err)
+		}
+	}
+
+	_, err := config.Parse([]byte(testConfig))
+	ok(t, err)
+}
+
+const testYAML = `
+canvas:
+  width: 1920
+  height: 1080
+  background:
+    color: "#202020"
+sources:
+  - id: cam
+    type: test
+    config:
+      pattern: 18
+layout:
+  width: 1920
+  height: 1080
+  rules:
+    - sources: 1
+      slots:
+        - {x: 0, y: 0, width: 1920, height: 1080, fit: contain}
+outputs:
+  - id: "null"
+    type: fake
+`
+
+func TestConfigYAML(t *testing.T) {
+	c, err := config.ParseYAML([]byte(testYAML))
+	ok(t, err)
+	equals(t, 1920, c.Canvas.Width)
+	equals(t, "#202020", c.Canvas.Background.Color)
+	equals(t, "contain", c.Layout.Rules[0].Slots[0].Fit)
+
+	_, err = config.ParseYAML([]byte(testYAML + "speed: 2\n"))
+	assert(t, err != nil, "unknown fields should be rejected")
+
+	instance, err := config.Build(c)
+	ok(t, err)
+	defer instance.Compositor.Stop()
+
+	width, height := instance.Compositor.Canvas()
+	equals(t, 1920, width)
+	equals(t, 1080, height)
+}
+
+func TestConfigApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := config.Parse([]byte(testConfig))
+	ok(t, err)
+
+	instance, err := config.Build(c)
+	ok(t, err)
+	defer instance.Compositor.Stop()
+
+	cam, _ := instance.Source("cam")
+	bars, _ := instance.Source("bars")
+
+	next, err := config.Parse([]byte(testConfig))
+	ok(t, err)
+	next.Sources[0].Config = map[string]interface{}{"pattern": 1}
+	next.Outputs = []config.Output{{ID: "window", Type: "display"}}
+	hidden := float32(0)
+	next.Overlays = []config.Overlay{
+		{ID: "logo", Location: writeTestPNG(t, dir, 64, 32), Anchor: "top-right"},
+		{ID: "badge", Location: writeTestPNG(t, dir, 64, 32), Opacity: &hidden},
+	}
+	ok(t, instance.Apply(next))
+
+	video, _ := instance.Source("bars")
+	assert(t, video == bars, "unchanged sources should be kept")
+	video, _ = instance.Source("cam")
+	assert(t, video != cam, "changed sources should be replaced")
+	equals(t, element.Videos{video, bars}, instance.Compositor.Videos())
+
+	_, found := instance.Compositor.Output("null")
+	assert(t, !found, "removed outputs should be removed")
+	_, found = instance.Compositor.Output("window")
+	assert(t, found, "new outputs should be added")
+	logo, found := instance.Compositor.ImageOverlay("logo")
+	assert(t, found, "new overlays should be added")
+	equals(t, float32(1), logo.Options().Opacity)
+	badge, _ := instance.Compositor.ImageOverlay("badge")
+	equals(t, float32(0), badge.Options().Opacity)
+
+	bad, err := config.Parse([]byte(testConfig))
+	ok(t, err)
+	bad.Sources = append(bad.Sources, config.Source{ID: "extra", Type: "test"})
+	assert(t, instance.Apply(bad) != nil, "a layout without a rule for 3 sources should be rejected")
+
+	bad.Sources = bad.Sources[:2]
+	bad.Canvas.Width = 640
+	bad.Canvas.Height = 360
+	assert(t, errors.Is(instance.Apply(bad), config.ErrRestart), "canvas changes should need a restart")
+
+	equals(t, 2, instance.Compositor.SourceCount())
+	_, found = instance.Compositor.Output("window")
+	assert(t, found, "rejected configurations should change nothing")
+}
+
+func TestConfigApplyFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := config.Parse([]byte(testConfig))
+	ok(t, err)
+
+	instance, err := config.Build(c)
+	ok(t, err)
+	defer instance.Compositor.Stop()
+
+	videos := instance.Compositor.Videos()
+	applied := instance.Config()
+
+	//the picture is gone after validation, it fails once the sources are built
+	logo := writeTestPNG(t, dir, 64, 32)
+	next, err := config.Parse([]byte(testConfig))
+	ok(t, err)
+	next.Sources[1].Config = map[string]interface{}{"pattern": 1}
+	next.Outputs = []config.Output{{ID: "window", Type: "display"}}
+	next.Overlays = []config.Overlay{{ID: "logo", Location: logo}}
+	ok(t, os.Remove(logo))
+
+	assert(t, instance.Apply(next) != nil, "an overlay without its picture should be rejected")
+	equals(t, videos, instance.Compositor.Videos())
+	assert(t, instance.Config() == applied, "the applied configuration should be kept")
+
+	_, found := instance.Compositor.Output("null")
+	assert(t, found, "outputs should be kept")
+	_, found = instance.Compositor.Output("window")
+	assert(t, !found, "outputs should not be added")
+	_, found = instance.Compositor.ImageOverlay("logo")
+	assert(t, !found, "overlays should not be added")
+}
+
+func TestConfigWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "show.json")
+	ok(t, ioutil.WriteFile(path, []byte(testConfig), 0644))
+
+	c, err := config.Load(path)
+	ok(t, err)
+
+	instance, err := config.Build(c)
+	ok(t, err)
+	defer instance.Compositor.Stop()
+
+	reloads := make(chan error, 4)
+	instance.Watch(path, 10*time.Millisecond, func(err error) {
+		reloads <- err
+	})
+	defer instance.StopWatching()
+
+	wait := func() error {
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(2 * time.Second):
+			t.Fatal("the configuration was not reloaded")
+			return nil
+		}
+	}
+
+	ok(t, ioutil.WriteFile(path, []byte(`{"name": "show", "sources": [`), 0644))
+	assert(t, wait() != nil, "invalid edits should be rejected")
+	equals(t, 2, instance.Compositor.SourceCount())
+
+	edited := strings.Replace(testConfig, `{"id": "bars", "type": "test"}`, `{"id": "bars", "type": "test", "config": {"pattern": 1}}`, 1)
+	ok(t, ioutil.WriteFile(path, []byte(edited), 0644))
+	ok(t, wait())
+
+	equals(t, float64(1), instance.Config().Sources[1].Config["pattern"])
+}
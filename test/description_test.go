@@ -0,0 +1,25 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestVideoFromDescription(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoFromDescription(`videotestsrc pattern=ball ! video/x-raw, framerate=30/1 ! textoverlay text="Camera 1"`, 640, 360)
+	ok(t, err)
+	assert(t, strings.HasPrefix(video.Name(), "source_"), "unexpected source name %s", video.Name())
+	ok(t, cmp.AddVideo(video))
+
+	for _, desc := range []string{"", "videotestsrc ! ! videoconvert", "videotestsrc pattern", "nosuchelement ! videoconvert"} {
+		_, err := element.NewVideoFromDescription(desc, 640, 360)
+		assert(t, errors.Is(err, element.ErrVideoDescription), "%q should be rejected, got %v", desc, err)
+	}
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestVideoFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+
+	options := element.FallbackOptions{
+		Location: writeTestPNG(t, dir, 640, 360),
+		Timeout:  500 * time.Millisecond,
+		Text:     "Reconnecting...",
+	}
+
+	ok(t, video.SetFallback(options))
+	ok(t, cmp.AddVideo(video))
+	equals(t, element.ErrVideoFallbackLinked, video.SetFallback(options))
+}
+
+func TestVideoFallbackSignalLost(t *testing.T) {
+	requireElements(t, "rtpvp8depay", "vp8dec")
+
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	packets := encodeRTP(t, "vp8enc", "rtpvp8pay", 96)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+	ok(t, video.SetFallback(element.FallbackOptions{
+		Location: writeTestPNG(t, dir, 640, 360),
+		Timeout:  300 * time.Millisecond,
+	}))
+
+	events := make(chan element.Event, 16)
+	video.OnEvent(func(event element.Event) {
+		if event.Type == element.EventPlaceholderShown || event.Type == element.EventPlaceholderHidden {
+			events <- event
+		}
+	})
+
+	ok(t, cmp.AddVideo(video))
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+	cmp.Start()
+
+	//the placeholder is shown until the first frame
+	for _, packet := range packets {
+		ok(t, video.Push(packet))
+		time.Sleep(time.Millisecond)
+	}
+
+	expect := func(eventType element.EventType) {
+		select {
+		case event := <-events:
+			equals(t, eventType, event.Type)
+			equals(t, video.Name(), event.Source)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", eventType)
+		}
+	}
+
+	expect(element.EventPlaceholderHidden)
+	assert(t, video.Stats().Frames > 0, "frames should have been decoded")
+
+	//the sender stops, the placeholder is back after the timeout
+	expect(element.EventPlaceholderShown)
+	since := video.Stats().SinceLastBuffer
+	assert(t, since >= 300*time.Millisecond, "the placeholder was shown %s after the last frame", since)
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//writeTestVideo encodes seconds of test pattern with a key frame every second
+func writeTestVideo(t *testing.T, dir string, seconds int) string {
+	location := filepath.Join(dir, "pattern.mkv")
+
+	pipeline, elements := newTestPipeline(t, "fixture", "videotestsrc", "capsfilter", "x264enc", "h264parse", "matroskamux", "filesink")
+
+	caps, err := gstreamer.NewCapsFromString("video/x-raw,width=320,height=240,framerate=30/1")
+	ok(t, err)
+	elements[0].Set("num-buffers", seconds*30)
+	elements[1].Set("caps", caps)
+	elements[2].Set("key-int-max", uint32(30))
+	elements[5].Set("location", location)
+
+	runToEOS(t, pipeline, 10*time.Second)
+
+	return location
+}
+
+func TestVideoFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	location := writeTestVideo(t, dir, 3)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoFile(640, 360, location)
+	ok(t, err)
+	assert(t, strings.HasPrefix(video.Name(), "source_"), "unexpected source name %s", video.Name())
+
+	ok(t, cmp.AddVideo(video))
+	ok(t, cmp.AddAudio(video.Audio()))
+
+	events := make(chan element.Event, 1)
+	cmp.OnEvent(func(e element.Event) {
+		if e.Type == element.EventEOS {
+			select {
+			case events <- e:
+			default:
+			}
+		}
+	})
+
+	cmp.Start()
+	defer cmp.Stop()
+	time.Sleep(500 * time.Millisecond)
+
+	//key frames are a second apart, a key unit seek to 2s lands on 2s
+	video.Pause()
+	ok(t, video.Seek(2*time.Second))
+	diff := video.Position() - 2*time.Second
+	assert(t, diff < 100*time.Millisecond && diff > -100*time.Millisecond, "expected the position at 2s, got %s", video.Position())
+	video.Resume()
+
+	select {
+	case e := <-events:
+		equals(t, video.Name(), e.Source)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an EOS event")
+	}
+}
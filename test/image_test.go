@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestVideoImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	layout := compositor.NewLayout(1280, 720)
+	rule := compositor.NewLayoutRule()
+	slot := compositor.NewLayoutSlot(0, 0, 1280, 720)
+	slot.SetFit(element.FitContain)
+	rule.AddSlot(slot)
+	layout.AddRule(rule, 1)
+	cmp.SetLayout(layout)
+
+	location := writeTestPNG(t, dir, 400, 400)
+	video, err := element.NewVideoImage(1280, 720, location)
+	ok(t, err)
+	equals(t, location, video.Image())
+
+	ok(t, cmp.AddVideo(video))
+	cmp.Start()
+	defer cmp.Stop()
+
+	_, err = element.NewVideoImage(1280, 720, filepath.Join(dir, "missing.png"))
+	assert(t, err != nil, "expected an error for a missing picture")
+	assert(t, video.SetImage(filepath.Join(dir, "missing.png")) != nil, "expected an error for a missing picture")
+	equals(t, location, video.Image())
+}
@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestVideoLabel(t *testing.T) {
+	video, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+
+	label := video.Label()
+	assert(t, label != nil, "every source should have a label")
+	assert(t, !label.Visible(), "labels start hidden")
+
+	options := element.DefaultLabelOptions()
+	options.Text = "Guest"
+	options.Position = element.LabelTopRight
+	label.Configure(options)
+	label.SetVisible(true)
+
+	equals(t, "Guest", label.Text())
+	assert(t, label.Visible(), "label should be visible")
+
+	label.SetText("Host")
+	equals(t, "Host", label.Text())
+	equals(t, element.LabelTopRight, label.Options().Position)
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gocompositor/pkg/metrics"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	handler := metrics.NewHandler()
+	handler.Add("main", cmp)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/metrics")
+	ok(t, err)
+	defer response.Body.Close()
+
+	equals(t, http.StatusOK, response.StatusCode)
+	assert(t, strings.HasPrefix(response.Header.Get("Content-Type"), "text/plain"), "unexpected content type")
+
+	body, err := ioutil.ReadAll(response.Body)
+	ok(t, err)
+
+	for _, line := range []string{
+		"# TYPE gocompositor_sources gauge",
+		`gocompositor_sources{compositor="main"} 1`,
+		`gocompositor_bus_errors_total{compositor="main"} 0`,
+		`gocompositor_source_reconnects_total{compositor="main",source="` + video.Name() + `"} 0`,
+		`gocompositor_source_rtp_received_total{compositor="main",source="` + video.Name() + `"} 0`,
+	} {
+		assert(t, strings.Contains(string(body), line+"\n"), "missing %q", line)
+	}
+
+	handler.Remove("main")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	equals(t, "", recorder.Body.String())
+}
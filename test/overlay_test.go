@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+)
+
+func writeTestPNG(tb testing.TB, dir string, width int, height int) string {
+	location := filepath.Join(dir, "logo.png")
+
+	file, err := os.Create(location)
+	ok(tb, err)
+	defer file.Close()
+
+	ok(tb, png.Encode(file, image.NewNRGBA(image.Rect(0, 0, width, height))))
+
+	return location
+}
+
+func TestImageOverlay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	options := compositor.ImageOverlayOptions{
+		Location: writeTestPNG(t, dir, 64, 32),
+		X:        16,
+		Y:        16,
+		Anchor:   compositor.AnchorTopRight,
+		Opacity:  0.8,
+	}
+
+	logo, err := cmp.AddImageOverlay("logo", options)
+	ok(t, err)
+	equals(t, "logo", logo.Name())
+
+	_, err = cmp.AddImageOverlay("logo", options)
+	equals(t, compositor.ErrOverlayExists, err)
+
+	logo.SetOpacity(0.5)
+	logo.SetVisible(false)
+	equals(t, float32(0.5), logo.Options().Opacity)
+
+	//a zero opacity option is opaque
+	badge, err := cmp.AddImageOverlay("badge", compositor.ImageOverlayOptions{Location: options.Location})
+	ok(t, err)
+	equals(t, float32(1), badge.Options().Opacity)
+
+	ok(t, cmp.RemoveImageOverlay("logo"))
+	equals(t, compositor.ErrOverlayNotFound, cmp.RemoveImageOverlay("logo"))
+
+	_, err = cmp.AddImageOverlay("logo", options)
+	ok(t, err)
+
+	_, err = cmp.AddImageOverlay("missing", compositor.ImageOverlayOptions{Location: filepath.Join(dir, "missing.png")})
+	assert(t, err != nil, "missing pictures must be rejected")
+}
+
+func TestBackground(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	ok(t, cmp.SetBackgroundColor(0xFF204080))
+	ok(t, cmp.SetBackgroundImage(writeTestPNG(t, dir, 320, 180)))
+	cmp.ClearBackground()
+
+	assert(t, cmp.SetBackgroundImage(filepath.Join(dir, "missing.png")) != nil, "missing pictures must be rejected")
+}
+
+func TestBackgroundVideo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	location := writeTestVideo(t, dir, 1)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	//set before Start, the video follows the compositor state
+	background, err := cmp.NewBackgroundVideo("file://" + location)
+	ok(t, err)
+	ok(t, cmp.SetBackground(background))
+	cmp.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for background.Stats().Frames == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert(t, background.Stats().Frames > 0, "the background video didn't play")
+
+	//a one second file keeps playing once it loops
+	played := background.Stats().Frames
+	time.Sleep(1500 * time.Millisecond)
+	assert(t, background.Stats().Frames > played, "the background video didn't loop")
+
+	ok(t, cmp.SetBackgroundColor(0xFF000000))
+	cmp.ClearBackground()
+}
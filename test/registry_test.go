@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestSourceRegistry(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewSource("test", 640, 360, map[string]interface{}{"pattern": float64(18)})
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	_, err = element.NewSource("missing", 640, 360, nil)
+	assert(t, errors.Is(err, element.ErrSourceTypeUnknown), "unexpected error %v", err)
+
+	_, err = element.NewSource("rtsp", 640, 360, map[string]interface{}{"latency": 100})
+	assert(t, errors.Is(err, element.ErrSourceParam), "expected a missing location, got %v", err)
+
+	_, err = element.NewSource("test", 640, 360, map[string]interface{}{"pattern": "ball"})
+	assert(t, errors.Is(err, element.ErrSourceParam), "expected a type error, got %v", err)
+
+	_, err = element.NewSource("test", 640, 360, map[string]interface{}{"colour": 1})
+	assert(t, errors.Is(err, element.ErrSourceParam), "expected an unknown parameter, got %v", err)
+
+	var created element.Params
+	custom := element.SourceType{
+		Name: "custom-test",
+		Params: []element.Param{
+			{Name: "delay", Type: element.ParamDuration, Default: "1s"},
+			{Name: "names", Type: element.ParamStrings},
+		},
+		Factory: func(width int, height int, params element.Params) (element.Video, error) {
+			created = params
+			return element.NewVideoTest(width, height)
+		},
+	}
+
+	ok(t, element.RegisterSource(custom))
+	equals(t, element.ErrSourceTypeExists, element.RegisterSource(custom))
+
+	_, err = element.NewSource("custom-test", 640, 360, map[string]interface{}{"names": []interface{}{"a", "b"}})
+	ok(t, err)
+	equals(t, time.Second, created.Duration("delay"))
+	equals(t, []string{"a", "b"}, created.Strings("names"))
+}
@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+//rtpPacket builds a RTP packet with a marker bit set on the last packet of a frame
+func rtpPacket(payloadType uint8, sequence uint16, timestamp uint32, marker bool, payload []byte) []byte {
+	packet := make([]byte, 12, 12+len(payload))
+	packet[0] = 0x80
+	packet[1] = payloadType
+	if marker {
+		packet[1] |= 0x80
+	}
+
+	binary.BigEndian.PutUint16(packet[2:], sequence)
+	binary.BigEndian.PutUint32(packet[4:], timestamp)
+	binary.BigEndian.PutUint32(packet[8:], 0x1234abcd)
+
+	return append(packet, payload...)
+}
+
+//encodeRTP payloads a few frames of test pattern and returns the RTP packets
+func encodeRTP(t *testing.T, encoder string, payloader string, pt int) [][]byte {
+	pipeline, elements := newTestPipeline(t, "rtp_"+encoder, "videotestsrc", "capsfilter", encoder, payloader, "appsink")
+
+	caps, err := gstreamer.NewCapsFromString("video/x-raw,width=320,height=240,framerate=30/1")
+	ok(t, err)
+	elements[0].Set("num-buffers", 15)
+	elements[1].Set("caps", caps)
+	elements[3].Set("pt", uint32(pt))
+	if payloader == "rtph264pay" {
+		//the RTC source has no sprop-parameter-sets, SPS and PPS are sent in band
+		elements[3].Set("config-interval", -1)
+	}
+	elements[4].Set("emit-signals", true)
+	elements[4].Set("sync", false)
+
+	var mutex sync.Mutex
+	var packets [][]byte
+	elements[4].SetOnSampleAddedCallback(func(e gstreamer.Element, sample gstreamer.Sample) {
+		mutex.Lock()
+		packets = append(packets, sample.Data)
+		mutex.Unlock()
+	})
+
+	runToEOS(t, pipeline, 30*time.Second)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return packets
+}
+
+func TestVideoRTCCodecs(t *testing.T) {
+	codecs := []struct {
+		codec     element.VideoRTCCodec
+		pt        int
+		encoders  []string
+		payloader string
+		depay     string
+		decoders  []string
+	}{
+		{element.VideoRTCCodecVP8, 96, []string{"vp8enc"}, "rtpvp8pay", "rtpvp8depay", []string{"vp8dec"}},
+		{element.VideoRTCCodecVP9, 98, []string{"vp9enc"}, "rtpvp9pay", "rtpvp9depay", []string{"vp9dec"}},
+		{element.VideoRTCCodecH264, 102, []string{"x264enc"}, "rtph264pay", "rtph264depay", []string{"avdec_h264"}},
+		{element.VideoRTCCodecAV1, 45, []string{"av1enc", "rav1enc", "svtav1enc"}, "rtpav1pay", "rtpav1depay", []string{"dav1ddec", "av1dec"}},
+	}
+
+	for _, c := range codecs {
+		c := c
+		t.Run(string(c.codec), func(t *testing.T) {
+			requireElements(t, c.depay)
+			firstElement(t, c.decoders...)
+
+			packets := encodeRTP(t, firstElement(t, c.encoders...), c.payloader, c.pt)
+			assert(t, len(packets) > 0, "no %s packet was payloaded", c.codec)
+
+			cmp, err := compositor.NewCompositor()
+			ok(t, err)
+			defer cmp.Stop()
+
+			video, err := element.NewVideoRTC(640, 360, c.codec)
+			ok(t, err)
+			ok(t, cmp.AddVideo(video))
+
+			_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+			ok(t, err)
+
+			cmp.Start()
+			for _, packet := range packets {
+				ok(t, video.Push(packet))
+				time.Sleep(time.Millisecond)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for video.Stats().Frames == 0 && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			assert(t, video.Stats().Frames > 0, "no %s frame was decoded", c.codec)
+		})
+	}
+
+	//H264 single NAL unit packet carrying a SPS
+	sps := []byte{0x67, 0x42, 0xc0, 0x1f, 0xda, 0x02, 0x80, 0xbf, 0xe5, 0xc0, 0x44}
+
+	custom, err := element.NewVideoRTCWithPayload(640, 360, element.VideoRTCCodecH264, 127)
+	ok(t, err)
+	ok(t, custom.Push(rtpPacket(127, 0, 0, true, sps)))
+
+	for _, codec := range []element.VideoRTCCodec{element.VideoRTCCodecOpus, "MPEG4"} {
+		_, err := element.NewVideoRTC(640, 360, codec)
+		equals(t, element.ErrVideoRTCCodec, err)
+	}
+}
+
+func TestVideoRTCStats(t *testing.T) {
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+
+	video.SetLatency(100)
+	video.SetDropOnLatency(true)
+
+	payload := []byte{0x10, 0x50, 0x01, 0x00, 0x9d, 0x01, 0x2a}
+	for _, seq := range []uint16{65533, 65534, 0, 65535, 2} {
+		ok(t, video.Push(rtpPacket(96, seq, uint32(seq)*3000, true, payload)))
+	}
+
+	stats := video.Stats()
+	equals(t, uint64(5), stats.RTP.Received)
+	equals(t, uint64(1), stats.RTP.Reordered)
+	equals(t, uint64(1), stats.RTP.Lost)
+}
+
+func TestVideoRTCJitter(t *testing.T) {
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+
+	//30ms of media every 40ms, each transit grows by 10ms or 900 units at 90kHz
+	arrival := time.Unix(1700000000, 0)
+	payload := []byte{0x10, 0x50, 0x01, 0x00, 0x9d, 0x01, 0x2a}
+	for i := 0; i < 11; i++ {
+		timestamp := uint32(0xffffffff - 10000 + i*2700)
+		ok(t, video.PushAt(rtpPacket(96, uint16(i), timestamp, true, payload), arrival.Add(time.Duration(i)*40*time.Millisecond)))
+	}
+
+	//J += (|D| - J) / 16 from J = 0, ten times
+	jitter := 900 * (1 - math.Pow(15.0/16.0, 10))
+	expected := time.Duration(jitter * float64(time.Second) / 90000)
+
+	stats := video.Stats()
+	diff := stats.RTP.Jitter - expected
+	assert(t, diff < time.Microsecond && diff > -time.Microsecond, "expected a jitter of %s, got %s", expected, stats.RTP.Jitter)
+}
+
+func TestVideoRTCKeyframeRequest(t *testing.T) {
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+
+	var reasons []element.KeyframeReason
+	video.OnKeyframeRequest(func(reason element.KeyframeReason) {
+		reasons = append(reasons, reason)
+	})
+	video.SetKeyframeRequestInterval(0)
+
+	keyframe := []byte{0x10, 0x50, 0x01, 0x00, 0x9d, 0x01, 0x2a}
+	interframe := []byte{0x10, 0x51, 0x01, 0x00}
+
+	ok(t, video.Push(rtpPacket(96, 0, 0, true, interframe)))
+	ok(t, video.Push(rtpPacket(96, 1, 3000, true, keyframe)))
+	ok(t, video.Push(rtpPacket(96, 2, 6000, true, interframe)))
+	ok(t, video.Push(rtpPacket(96, 4, 12000, true, interframe)))
+	equals(t, []element.KeyframeReason{element.KeyframeStartup, element.KeyframeLoss}, reasons)
+
+	video.SetKeyframeRequestInterval(time.Hour)
+	ok(t, video.Push(rtpPacket(96, 5, 15000, true, interframe)))
+	ok(t, video.Push(rtpPacket(96, 7, 21000, true, interframe)))
+	equals(t, 2, len(reasons))
+}
+
+func TestVideoRTCPushPacket(t *testing.T) {
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         true,
+			PayloadType:    96,
+			SequenceNumber: 1,
+			Timestamp:      3000,
+			SSRC:           0x1234abcd,
+		},
+		Payload: []byte{0x10, 0x50, 0x01, 0x00, 0x9d, 0x01, 0x2a},
+	}
+	ok(t, video.PushPacketAt(packet, time.Now().Add(-20*time.Millisecond)))
+
+	received := &rtp.Packet{}
+	ok(t, received.Unmarshal(rtpPacket(96, 2, 6000, true, packet.Payload)))
+	ok(t, video.PushPacket(received))
+
+	packet.PayloadType = 97
+	equals(t, element.ErrRTPPayloadType, video.PushPacket(packet))
+	equals(t, element.ErrRTPPacket, video.Push([]byte{0x00, 0x01}))
+
+	stats := video.Stats()
+	equals(t, uint64(2), stats.RTP.Received)
+	equals(t, uint64(2), stats.RTP.Dropped)
+	equals(t, uint64(2), stats.DroppedFrames)
+}
+
+func TestVideoRTCQueueLimits(t *testing.T) {
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+	assert(t, video.Ready(), "an empty source should be ready")
+
+	ok(t, video.SetQueueLimits(element.QueueLimits{
+		MaxBytes: 64 * 1024,
+		MaxTime:  500 * time.Millisecond,
+		Policy:   element.OverflowBlock,
+	}))
+	assert(t, video.Ready(), "an empty source should be ready")
+	ok(t, video.Push(rtpPacket(96, 0, 0, true, []byte{0x10, 0x50, 0x01, 0x00, 0x9d, 0x01, 0x2a})))
+
+	//max-time is a guint64, 5s doesn't fit in a gint
+	limits := element.QueueLimits{MaxBytes: 8 << 30, MaxTime: 5 * time.Second, Policy: element.OverflowDropNewest}
+	ok(t, video.SetQueueLimits(limits))
+	equals(t, limits, video.QueueLimits())
+
+	source, err := element.NewSource("rtc", 640, 360, map[string]interface{}{"overflow": "drop-newest", "queue-time": "10s"})
+	ok(t, err)
+	equals(t, 10*time.Second, source.(element.VideoRTC).QueueLimits().MaxTime)
+
+	//dropping the oldest buffer needs a leaky appsrc, GStreamer 1.20 or newer
+	oldest := element.QueueLimits{MaxBytes: 64 * 1024, MaxTime: time.Second, Policy: element.OverflowDropOldest}
+	if err := video.SetQueueLimits(oldest); err != nil {
+		assert(t, errors.Is(err, element.ErrOverflowPolicy), "expected an unsupported policy, got %v", err)
+		equals(t, limits, video.QueueLimits())
+	} else {
+		equals(t, oldest, video.QueueLimits())
+	}
+
+	_, err = element.NewSource("rtc", 640, 360, map[string]interface{}{"overflow": "grow"})
+	assert(t, errors.Is(err, element.ErrSourceParam), "expected an unknown overflow, got %v", err)
+}
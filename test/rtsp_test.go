@@ -0,0 +1,433 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestVideoRTSP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoRTSP(640, 360, "rtsp://127.0.0.1:8554/test", 200)
+	ok(t, err)
+	assert(t, strings.HasPrefix(video.Name(), "source_"), "unexpected source name %s", video.Name())
+
+	video.SetBackoff(100*time.Millisecond, 2*time.Second)
+	ok(t, video.SetFallback(element.FallbackOptions{
+		Location: writeTestPNG(t, dir, 640, 360),
+		Text:     "Reconnecting...",
+	}))
+
+	ok(t, cmp.AddVideo(video))
+	cmp.Start()
+	assert(t, !video.Connected(), "the source shouldn't be connected before its first frame")
+	cmp.Stop()
+}
+
+func TestVideoRTSPOptions(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{
+		Location:   "rtsp://127.0.0.1:8554/test",
+		Latency:    100,
+		Transport:  element.RTSPTransportTCP,
+		Username:   "admin",
+		Password:   "secret",
+		TCPTimeout: 5 * time.Second,
+		KeepAlive:  true,
+		Track:      2,
+	})
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	_, err = element.NewSource("rtsp", 640, 360, map[string]interface{}{
+		"location":  "rtsp://127.0.0.1:8554/test",
+		"transport": "tcp",
+		"timeout":   "2s",
+	})
+	ok(t, err)
+
+	_, err = element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{Location: "rtsp://127.0.0.1:8554/test", Transport: "quic"})
+	equals(t, element.ErrRTSPTransport, err)
+}
+
+func TestVideoRTSPAudio(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoRTSP(640, 360, "rtsp://127.0.0.1:8554/test", 200)
+	ok(t, err)
+	assert(t, video.Audio() == nil, "audio should be disabled by default")
+
+	video, err = element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{
+		Location: "rtsp://127.0.0.1:8554/test",
+		Audio:    true,
+	})
+	ok(t, err)
+	assert(t, video.Audio() != nil, "expected an audio branch")
+
+	ok(t, cmp.AddVideo(video))
+	ok(t, cmp.AddAudio(video.Audio()))
+	cmp.Start()
+	cmp.Stop()
+}
+
+//waitFrames waits for a source to decode its first frames
+func waitFrames(t *testing.T, video element.Video, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for video.Stats().Frames == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	assert(t, video.Stats().Frames > 0, "no frame was decoded from %s", video.Name())
+}
+
+func TestVideoRTSPTestLaunch(t *testing.T) {
+	launch, err := exec.LookPath("test-launch")
+	if err != nil {
+		t.Skip("test-launch is not installed")
+	}
+	requireElements(t, "rtspsrc", "videotestsrc", "vp8enc", "rtpvp8pay", "rtpvp8depay", "vp8dec")
+
+	const port = 8654
+	server := exec.Command(launch, "-p", strconv.Itoa(port), "( videotestsrc is-live=true ! video/x-raw,width=320,height=240 ! vp8enc deadline=1 ! rtpvp8pay name=pay0 pt=96 )")
+	ok(t, server.Start())
+	defer func() {
+		server.Process.Kill()
+		server.Wait()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err == nil {
+			conn.Close()
+			break
+		}
+		assert(t, time.Now().Before(deadline), "test-launch didn't listen on %d", port)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	video, err := element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{
+		Location:  fmt.Sprintf("rtsp://127.0.0.1:%d/test", port),
+		Latency:   100,
+		Transport: element.RTSPTransportTCP,
+	})
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	cmp.Start()
+	waitFrames(t, video, 10*time.Second)
+	assert(t, video.Connected(), "the source should be connected")
+}
+
+//rtspServer serves RTP packets interleaved on the RTSP connection to clients
+//authenticated with basic credentials, UDP setups are refused
+type rtspServer struct {
+	listener      net.Listener
+	authorization string
+	packets       [][]byte
+
+	mutex      sync.Mutex
+	conns      []net.Conn
+	challenged bool
+	playing    bool
+	transport  string
+}
+
+func newRTSPServer(t *testing.T, username string, password string, packets [][]byte) *rtspServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ok(t, err)
+
+	server := &rtspServer{
+		listener:      listener,
+		authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)),
+		packets:       packets,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.mutex.Lock()
+			server.conns = append(server.conns, conn)
+			server.mutex.Unlock()
+
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *rtspServer) url() string {
+	return fmt.Sprintf("rtsp://%s/test", s.listener.Addr())
+}
+
+//close stops listening and drops the clients
+func (s *rtspServer) close() {
+	s.listener.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *rtspServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	requests := textproto.NewReader(reader)
+
+	var writeMutex sync.Mutex
+	write := func(data []byte) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+
+		_, err := conn.Write(data)
+		return err
+	}
+
+	for {
+		//interleaved RTCP sent by the client
+		if b, err := reader.Peek(1); err == nil && b[0] == '$' {
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(reader, header); err != nil {
+				return
+			}
+			if _, err := reader.Discard(int(binary.BigEndian.Uint16(header[2:]))); err != nil {
+				return
+			}
+			continue
+		}
+
+		line, err := requests.ReadLine()
+		if err != nil {
+			return
+		}
+		headers, err := requests.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		if length, _ := strconv.Atoi(headers.Get("Content-Length")); length > 0 {
+			reader.Discard(length)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return
+		}
+		method := fields[0]
+		response := fmt.Sprintf("CSeq: %s\r\n", headers.Get("CSeq"))
+
+		if method != "OPTIONS" && headers.Get("Authorization") != s.authorization {
+			s.mutex.Lock()
+			s.challenged = true
+			s.mutex.Unlock()
+
+			write([]byte("RTSP/1.0 401 Unauthorized\r\n" + response + "WWW-Authenticate: Basic realm=\"test\"\r\n\r\n"))
+			continue
+		}
+
+		switch method {
+		case "OPTIONS":
+			response += "Public: OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER\r\n"
+		case "DESCRIBE":
+			sdp := "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=test\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\n" +
+				"m=video 0 RTP/AVP 96\r\na=rtpmap:96 VP8/90000\r\na=control:stream=0\r\n"
+			response += fmt.Sprintf("Content-Base: %s/\r\nContent-Type: application/sdp\r\nContent-Length: %d\r\n\r\n%s", s.url(), len(sdp), sdp)
+			write([]byte("RTSP/1.0 200 OK\r\n" + response))
+			continue
+		case "SETUP":
+			transport := headers.Get("Transport")
+			s.mutex.Lock()
+			s.transport = transport
+			s.mutex.Unlock()
+
+			if !strings.HasPrefix(transport, "RTP/AVP/TCP") {
+				write([]byte("RTSP/1.0 461 Unsupported Transport\r\n" + response + "\r\n"))
+				continue
+			}
+			response += "Session: 12345678;timeout=60\r\nTransport: RTP/AVP/TCP;unicast;interleaved=0-1\r\n"
+		case "PLAY":
+			response += "Session: 12345678\r\n"
+		default:
+			response += "Session: 12345678\r\n"
+		}
+
+		if err := write([]byte("RTSP/1.0 200 OK\r\n" + response + "\r\n")); err != nil {
+			return
+		}
+
+		if method == "PLAY" {
+			go s.play(write)
+		}
+	}
+}
+
+//play sends the packets on the interleaved channel 0 at about 30 frames a second
+func (s *rtspServer) play(write func([]byte) error) {
+	s.mutex.Lock()
+	s.playing = true
+	s.mutex.Unlock()
+
+	for _, packet := range s.packets {
+		frame := make([]byte, 4, 4+len(packet))
+		frame[0] = '$'
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(packet)))
+
+		if err := write(append(frame, packet...)); err != nil {
+			return
+		}
+
+		//the marker bit ends a frame
+		if packet[1]&0x80 != 0 {
+			time.Sleep(33 * time.Millisecond)
+		}
+	}
+}
+
+func TestVideoRTSPCredentials(t *testing.T) {
+	requireElements(t, "rtspsrc", "rtpvp8depay", "vp8dec")
+
+	server := newRTSPServer(t, "admin", "secret", encodeRTP(t, "vp8enc", "rtpvp8pay", 96))
+	defer server.close()
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	video, err := element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{
+		Location:  server.url(),
+		Latency:   100,
+		Transport: element.RTSPTransportTCP,
+		Username:  "admin",
+		Password:  "secret",
+	})
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	cmp.Start()
+	waitFrames(t, video, 10*time.Second)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	assert(t, server.challenged, "the source should have been asked for credentials")
+	assert(t, server.playing, "the source should have played the stream")
+	assert(t, strings.HasPrefix(server.transport, "RTP/AVP/TCP"), "unexpected transport %q", server.transport)
+}
+
+func TestVideoRTSPReconnect(t *testing.T) {
+	requireElements(t, "rtspsrc", "rtpvp8depay", "vp8dec")
+
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	server := newRTSPServer(t, "admin", "secret", encodeRTP(t, "vp8enc", "rtpvp8pay", 96))
+	defer server.close()
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	video, err := element.NewVideoRTSPWithOptions(640, 360, element.RTSPOptions{
+		Location:  server.url(),
+		Latency:   100,
+		Transport: element.RTSPTransportTCP,
+		Username:  "admin",
+		Password:  "secret",
+	})
+	ok(t, err)
+
+	video.SetBackoff(100*time.Millisecond, 400*time.Millisecond)
+	ok(t, video.SetFallback(element.FallbackOptions{
+		Location: writeTestPNG(t, dir, 640, 360),
+		//only the lost signal shows the placeholder once the few frames are played
+		Timeout: time.Minute,
+	}))
+
+	events := make(chan element.Event, 64)
+	video.OnEvent(func(event element.Event) {
+		if event.Type != element.EventStats {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	})
+
+	next := func(eventType element.EventType) element.Event {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case event := <-events:
+				if event.Type == eventType {
+					return event
+				}
+			case <-timeout:
+				t.Fatalf("no %s event", eventType)
+			}
+		}
+	}
+
+	ok(t, cmp.AddVideo(video))
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+	cmp.Start()
+
+	equals(t, server.url(), next(element.EventConnected).Message)
+	next(element.EventPlaceholderHidden)
+	assert(t, video.Connected(), "the source should be connected")
+
+	//the camera goes away, every attempt fails and the delay doubles up to the maximum
+	server.close()
+
+	next(element.EventPlaceholderShown)
+	next(element.EventDisconnected)
+	assert(t, !video.Connected(), "the source should be disconnected")
+
+	for _, expected := range []string{"attempt 1 in 100ms", "attempt 2 in 200ms", "attempt 3 in 400ms", "attempt 4 in 400ms"} {
+		equals(t, expected, next(element.EventRetrying).Message)
+	}
+	equals(t, uint64(3), video.Stats().Reconnects)
+}
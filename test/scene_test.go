@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+type fakeOverlay struct {
+	visible bool
+}
+
+func (o *fakeOverlay) SetVisible(visible bool) {
+	o.visible = visible
+}
+
+//alphaVideo records the alpha the compositor gives to its mixer pad
+type alphaVideo struct {
+	element.Video
+
+	alpha float32
+	mutex sync.Mutex
+}
+
+func (v *alphaVideo) SetAlpha(alpha float32) {
+	v.mutex.Lock()
+	v.alpha = alpha
+	v.mutex.Unlock()
+
+	v.Video.SetAlpha(alpha)
+}
+
+func (v *alphaVideo) Alpha() float32 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.alpha
+}
+
+func newAlphaVideo(t *testing.T) *alphaVideo {
+	video, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+
+	return &alphaVideo{Video: video}
+}
+
+func newSceneLayout() *compositor.Layout {
+	layout := compositor.NewLayout(1280, 720)
+
+	single := compositor.NewLayoutRule()
+	single.AddSlot(compositor.NewLayoutSlot(0, 0, 1280, 720))
+
+	double := compositor.NewLayoutRule()
+	double.AddSlot(compositor.NewLayoutSlot(0, 0, 640, 360))
+	double.AddSlot(compositor.NewLayoutSlot(640, 0, 640, 360))
+
+	layout.AddRule(single, 1)
+	layout.AddRule(double, 2)
+
+	return layout
+}
+
+func TestSceneSwitch(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	camera, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+	screen, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+
+	ok(t, cmp.AddVideo(camera))
+	ok(t, cmp.AddVideo(screen))
+
+	slate := &fakeOverlay{}
+	intro := compositor.NewScene("intro", newSceneLayout())
+	intro.AddOverlay(slate)
+
+	interview := compositor.NewScene("interview", newSceneLayout())
+	interview.AddVideo(camera)
+	interview.AddVideo(screen)
+
+	ok(t, cmp.AddScene(intro))
+	ok(t, cmp.AddScene(interview))
+	equals(t, compositor.ErrSceneExists, cmp.AddScene(compositor.NewScene("intro", nil)))
+	equals(t, compositor.ErrSceneNotFound, cmp.SwitchScene("outro"))
+
+	ok(t, cmp.SwitchScene("intro"))
+	equals(t, "intro", cmp.CurrentScene())
+	assert(t, slate.visible, "intro overlay should be visible")
+
+	ok(t, cmp.SwitchSceneWithTransition("interview", compositor.Transition{
+		Type:     compositor.TransitionFade,
+		Duration: 100 * time.Millisecond,
+	}))
+	equals(t, "interview", cmp.CurrentScene())
+	assert(t, !slate.visible, "intro overlay should be hidden after switching")
+
+	assert(t, cmp.RemoveScene("interview") != nil, "active scene must not be removable")
+	ok(t, cmp.RemoveScene("intro"))
+}
+
+func TestSceneAlpha(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	camera := newAlphaVideo(t)
+	screen := newAlphaVideo(t)
+	ok(t, cmp.AddVideo(camera))
+	ok(t, cmp.AddVideo(screen))
+
+	solo := compositor.NewScene("solo", newSceneLayout())
+	solo.AddVideo(camera)
+	both := compositor.NewScene("both", newSceneLayout())
+	both.AddVideo(camera)
+	both.AddVideo(screen)
+	ok(t, cmp.AddScene(solo))
+	ok(t, cmp.AddScene(both))
+
+	ok(t, cmp.SwitchScene("solo"))
+	equals(t, float32(1), camera.Alpha())
+	equals(t, float32(0), screen.Alpha())
+
+	ok(t, cmp.SwitchSceneWithTransition("both", compositor.Transition{Type: compositor.TransitionFade, Duration: 200 * time.Millisecond}))
+	equals(t, float32(1), camera.Alpha())
+	equals(t, float32(1), screen.Alpha())
+
+	//the compositor isn't held by a fade, a cut cancels it
+	done := make(chan error, 1)
+	go func() {
+		done <- cmp.SwitchSceneWithTransition("solo", compositor.Transition{Type: compositor.TransitionFade, Duration: 10 * time.Second})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	equals(t, "both", cmp.CurrentScene())
+	ok(t, cmp.SwitchScene("both"))
+
+	select {
+	case err := <-done:
+		ok(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the fade should be cancelled")
+	}
+
+	equals(t, "both", cmp.CurrentScene())
+	equals(t, float32(1), camera.Alpha())
+	equals(t, float32(1), screen.Alpha())
+}
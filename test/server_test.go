@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/server"
+)
+
+func request(t *testing.T, url string, method string, body string) *http.Response {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	ok(t, err)
+
+	response, err := http.DefaultClient.Do(req)
+	ok(t, err)
+
+	return response
+}
+
+func expectStatus(t *testing.T, url string, method string, body string, status int) {
+	response := request(t, url, method, body)
+	response.Body.Close()
+	equals(t, status, response.StatusCode)
+}
+
+func TestServerCompositor(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", server.NewServer()))
+
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	base := s.URL + "/api/compositors"
+	expectStatus(t, base, "POST", `{"id": "main"}`, http.StatusCreated)
+	expectStatus(t, base, "POST", `{"id": "main"}`, http.StatusConflict)
+	expectStatus(t, base, "POST", `{"id": "other", "unknown": 1}`, http.StatusBadRequest)
+
+	expectStatus(t, base+"/main/sources", "POST", `{"id": "cam", "type": "test", "config": {"pattern": 18}}`, http.StatusCreated)
+	expectStatus(t, base+"/main/sources", "POST", `{"id": "bad", "type": "nope"}`, http.StatusBadRequest)
+	expectStatus(t, base+"/main/outputs", "POST", `{"id": "null", "type": "fake"}`, http.StatusCreated)
+	expectStatus(t, base+"/main/outputs", "POST", `{"id": "rec", "type": "file"}`, http.StatusBadRequest)
+
+	layout := `{"width": 1280, "height": 720, "rules": [{"sources": 1, "slots": [{"x": 0, "y": 0, "width": 1280, "height": 720, "fit": "contain"}]}]}`
+	expectStatus(t, base+"/main/layout", "PUT", layout, http.StatusOK)
+	expectStatus(t, base+"/main/layout", "PUT", `{"width": 1280, "height": 720, "rules": [{"sources": 2, "slots": []}]}`, http.StatusBadRequest)
+
+	expectStatus(t, base+"/main/scenes", "POST", `{"name": "full", "layout": `+layout+`, "sources": ["cam"]}`, http.StatusCreated)
+	expectStatus(t, base+"/main/scene", "PUT", `{"scene": "full", "transition": "cut"}`, http.StatusOK)
+	expectStatus(t, base+"/main/scene", "PUT", `{"scene": "missing"}`, http.StatusNotFound)
+	expectStatus(t, base+"/main/state", "PUT", `{"state": "playing"}`, http.StatusOK)
+
+	response := request(t, base+"/main", "GET", "")
+	var state server.CompositorState
+	ok(t, json.NewDecoder(response.Body).Decode(&state))
+	response.Body.Close()
+
+	equals(t, "playing", state.State)
+	equals(t, "full", state.Scene)
+	equals(t, 1, len(state.Sources))
+	equals(t, "cam", state.Sources[0].ID)
+	equals(t, []server.OutputState{{ID: "null", Type: "fake"}}, state.Outputs)
+
+	response = request(t, base+"/main/stats", "GET", "")
+	var stats server.StatsResponse
+	ok(t, json.NewDecoder(response.Body).Decode(&stats))
+	response.Body.Close()
+
+	equals(t, 1, stats.Sources)
+	_, found := stats.Source["cam"]
+	assert(t, found, "stats should be keyed by source id")
+
+	expectStatus(t, base+"/main/sources/cam", "DELETE", "", http.StatusNoContent)
+	expectStatus(t, base+"/main/sources/cam", "DELETE", "", http.StatusNotFound)
+	expectStatus(t, base+"/main/outputs/null", "DELETE", "", http.StatusNoContent)
+	expectStatus(t, base+"/main", "DELETE", "", http.StatusNoContent)
+	expectStatus(t, base+"/main", "GET", "", http.StatusNotFound)
+}
+
+func TestServerEvents(t *testing.T) {
+	s := httptest.NewServer(server.NewServer())
+	defer s.Close()
+
+	base := s.URL + "/compositors"
+	expectStatus(t, base, "POST", `{"id": "main", "stats_interval": "20ms"}`, http.StatusCreated)
+	expectStatus(t, base+"/main/sources", "POST", `{"id": "cam", "type": "test"}`, http.StatusCreated)
+	defer expectStatus(t, base+"/main", "DELETE", "", http.StatusNoContent)
+
+	response := request(t, base+"/main/events", "GET", "")
+	defer response.Body.Close()
+	assert(t, strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream"), "events should be a SSE stream")
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			var event server.Event
+			ok(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			equals(t, "stats", event.Type)
+			equals(t, "cam", event.Source)
+			assert(t, event.Stats != nil, "stats events should carry the stats")
+			return
+		case <-timeout:
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func TestServerSchemas(t *testing.T) {
+	s := httptest.NewServer(server.NewServer())
+	defer s.Close()
+
+	for name := range server.Schemas {
+		response := request(t, s.URL+"/schemas/"+name, "GET", "")
+
+		var schema map[string]interface{}
+		ok(t, json.NewDecoder(response.Body).Decode(&schema))
+		response.Body.Close()
+		equals(t, "object", schema["type"])
+	}
+
+	expectStatus(t, s.URL+"/schemas/missing", "GET", "", http.StatusNotFound)
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestSlideDeck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocompositor")
+	ok(t, err)
+	defer os.RemoveAll(dir)
+
+	//slides of different sizes are all drawn at the slot size
+	var slides []string
+	for i, size := range [][2]int{{320, 180}, {180, 320}, {64, 64}} {
+		slideDir := filepath.Join(dir, strconv.Itoa(i))
+		ok(t, os.Mkdir(slideDir, 0755))
+		slides = append(slides, writeTestPNG(t, slideDir, size[0], size[1]))
+	}
+
+	_, err = element.NewSlideDeck(640, 360, nil)
+	equals(t, element.ErrSlideDeckEmpty, err)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	deck, err := element.NewSlideDeck(640, 360, slides)
+	ok(t, err)
+	equals(t, 3, deck.Len())
+
+	var changes []string
+	deck.OnEvent(func(event element.Event) {
+		if event.Type == element.EventSlide {
+			changes = append(changes, event.Message)
+		}
+	})
+
+	ok(t, cmp.AddVideo(deck))
+	cmp.Start()
+	defer cmp.Stop()
+
+	ok(t, deck.Next())
+	ok(t, deck.Next())
+	equals(t, element.ErrSlideOutOfRange, deck.Next())
+	ok(t, deck.Prev())
+	ok(t, deck.GoTo(0))
+	equals(t, element.ErrSlideOutOfRange, deck.GoTo(3))
+	equals(t, 0, deck.Current())
+	equals(t, []string{"1", "2", "1", "0"}, changes)
+
+	//a new slot size while running draws the slides again
+	deck.SetFit(element.FitCover)
+	deck.SetSize(320, 240)
+	ok(t, deck.GoTo(2))
+	time.Sleep(200 * time.Millisecond)
+	equals(t, 2, deck.Current())
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+)
+
+func TestVideoStats(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoTest(640, 360)
+	ok(t, err)
+
+	events := make(chan element.Event, 16)
+	cmp.OnEvent(func(event element.Event) {
+		if event.Type == element.EventStats {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	})
+
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	ok(t, cmp.AddVideo(video))
+	cmp.SetStatsInterval(20 * time.Millisecond)
+	defer cmp.SetStatsInterval(0)
+
+	cmp.Start()
+	defer cmp.Stop()
+
+	select {
+	case event := <-events:
+		equals(t, video.Name(), event.Source)
+		assert(t, event.Stats != nil, "stats events should carry the stats")
+		equals(t, video.Name(), event.Stats.Source)
+		assert(t, event.Stats.RTP == nil, "raw sources have no RTP stats")
+	case <-time.After(time.Second):
+		t.Fatal("no stats event")
+	}
+
+	waitFrames(t, video, 5*time.Second)
+
+	stats := cmp.Stats()
+	equals(t, 1, len(stats))
+	assert(t, stats[0].Frames > 0, "frames should be counted")
+	equals(t, 640, stats[0].Width)
+	equals(t, 360, stats[0].Height)
+	assert(t, stats[0].SinceLastBuffer >= 0, "a frame went through, got %s", stats[0].SinceLastBuffer)
+	equals(t, uint64(0), stats[0].DecodeErrors)
+}
+
+func TestVideoRTCSourceStats(t *testing.T) {
+	video, err := element.NewVideoRTC(640, 360, element.VideoRTCCodecVP8)
+	ok(t, err)
+
+	stats := video.Stats()
+	equals(t, time.Duration(-1), stats.SinceLastBuffer)
+	assert(t, stats.RTP != nil, "RTC sources should have RTP stats")
+	equals(t, uint64(0), stats.RTP.Received)
+}
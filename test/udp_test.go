@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vinijabes/gocompositor/pkg/compositor"
+	"github.com/vinijabes/gocompositor/pkg/compositor/element"
+	"github.com/vinijabes/gostreamer/pkg/gstreamer"
+)
+
+const testSDP = `v=0
+o=- 0 0 IN IP4 127.0.0.1
+s=Test stream
+c=IN IP4 127.0.0.1
+t=0 0
+m=audio 5002 RTP/AVP 0
+a=rtpmap:0 PCMU/8000
+m=video 5004 RTP/AVP 100 96
+a=rtpmap:100 MP4V-ES/90000
+a=rtpmap:96 VP8/90000
+`
+
+func TestVideoUDP(t *testing.T) {
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+
+	video, err := element.NewVideoUDP(640, 360, testSDP, 200)
+	ok(t, err)
+	equals(t, 5004, video.Port())
+	equals(t, element.VideoRTCCodecVP8, video.Codec())
+	ok(t, cmp.AddVideo(video))
+
+	_, err = element.NewVideoUDP(640, 360, "v=0\nm=audio 5002 RTP/AVP 0\n", 200)
+	equals(t, element.ErrSDPNoVideo, err)
+
+	_, err = element.NewVideoUDP(640, 360, "v=0\nm=video 5004 RTP/AVP 100\na=rtpmap:100 MP4V-ES/90000\n", 200)
+	equals(t, element.ErrSDPCodec, err)
+
+	_, err = element.NewVideoUDP(640, 360, "v=0\nm=video port RTP/AVP 96\n", 200)
+	assert(t, errors.Is(err, element.ErrSDPInvalid), "unexpected error %v", err)
+}
+
+func TestVideoUDPLoopback(t *testing.T) {
+	const port = 5014
+
+	sender, elements := newTestPipeline(t, "udp_sender", "videotestsrc", "capsfilter", "vp8enc", "rtpvp8pay", "udpsink")
+
+	caps, err := gstreamer.NewCapsFromString("video/x-raw,width=320,height=240,framerate=30/1")
+	ok(t, err)
+	elements[0].Set("is-live", true)
+	elements[1].Set("caps", caps)
+	elements[3].Set("pt", uint32(96))
+	elements[4].Set("host", "127.0.0.1")
+	elements[4].Set("port", port)
+
+	sdp := fmt.Sprintf("v=0\nc=IN IP4 127.0.0.1\nm=video %d RTP/AVP 96\na=rtpmap:96 VP8/90000\n", port)
+
+	cmp, err := compositor.NewCompositor()
+	ok(t, err)
+	defer cmp.Stop()
+
+	video, err := element.NewVideoUDP(640, 360, sdp, 50)
+	ok(t, err)
+	ok(t, cmp.AddVideo(video))
+
+	_, err = cmp.AddOutput("null", compositor.OutputOptions{Type: compositor.OutputFake})
+	ok(t, err)
+
+	cmp.Start()
+	sender.SetState(gstreamer.GstStatePlaying)
+	defer sender.SetState(gstreamer.GstStateNull)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for video.Stats().Frames == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	assert(t, video.Stats().Frames > 0, "no frame was decoded from the UDP stream")
+}
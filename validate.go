@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/vinijabes/gocompositor/pkg/config"
+)
+
+var ErrInvalidConfig = errors.New("Invalid configuration")
+
+func validateCommand(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gocompositor validate <file>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ErrNoConfig
+	}
+
+	invalid := false
+	for _, path := range flags.Args() {
+		c, err := config.Load(path)
+		if err != nil {
+			fmt.Println(err)
+			invalid = true
+			continue
+		}
+
+		fmt.Printf("%s: ok, %d sources, %d overlays, %d outputs\n", path, len(c.Sources), len(c.Overlays), len(c.Outputs))
+		if c.Layout == nil {
+			continue
+		}
+
+		//sources can be removed at runtime, the layout should place fewer of them too
+		for n := len(c.Sources) - 1; n > 0; n-- {
+			if !c.Layout.HasRule(n) {
+				fmt.Printf("%s: warning, the layout has no rule for %d sources\n", path, n)
+			}
+		}
+	}
+
+	if invalid {
+		return ErrInvalidConfig
+	}
+
+	return nil
+}